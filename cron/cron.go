@@ -0,0 +1,95 @@
+// Copyright 2015 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+// Package cron parses and evaluates standard 5-field cron expressions,
+// shared by every component that fires work on a schedule: the backup
+// and relation-GC schedulers in state/apiserver, and the per-unit
+// process supervisor.
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed standard 5-field cron expression (minute, hour,
+// day-of-month, month, day-of-week). It supports "*", single values,
+// and "*/n" step values in each field. A time matches only when all
+// five fields match, including when both day-of-month and day-of-week
+// are restricted.
+type Schedule struct {
+	minutes, hours, doms, months, dows map[int]bool
+}
+
+// Parse parses a standard 5-field cron expression.
+func Parse(expr string) (*Schedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid cron expression %q: expected 5 fields, got %d", expr, len(fields))
+	}
+	ranges := []struct{ min, max int }{
+		{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 6},
+	}
+	sets := make([]map[int]bool, 5)
+	for i, field := range fields {
+		set, err := parseField(field, ranges[i].min, ranges[i].max)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression %q: %v", expr, err)
+		}
+		sets[i] = set
+	}
+	return &Schedule{
+		minutes: sets[0],
+		hours:   sets[1],
+		doms:    sets[2],
+		months:  sets[3],
+		dows:    sets[4],
+	}, nil
+}
+
+func parseField(field string, min, max int) (map[int]bool, error) {
+	set := map[int]bool{}
+	step := 1
+	base := field
+	if i := strings.Index(field, "/"); i >= 0 {
+		base = field[:i]
+		n, err := strconv.Atoi(field[i+1:])
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("bad step in %q", field)
+		}
+		step = n
+	}
+	if base != "*" {
+		n, err := strconv.Atoi(base)
+		if err != nil || n < min || n > max {
+			return nil, fmt.Errorf("bad value in %q", field)
+		}
+		set[n] = true
+		return set, nil
+	}
+	for v := min; v <= max; v += step {
+		set[v] = true
+	}
+	return set, nil
+}
+
+// Next returns the first time strictly after after at which the
+// schedule fires, searching at most a year and a day ahead. It returns
+// the zero Time if the schedule does not fire within that window.
+func (s *Schedule) Next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	// Any schedule that fires at least once a year is found within a
+	// year and a day; the bound also keeps an impossible combination
+	// (e.g. day-of-month 31 in February) from looping forever.
+	limit := t.AddDate(1, 0, 1)
+	for t.Before(limit) {
+		if s.doms[t.Day()] && s.months[int(t.Month())] && s.dows[int(t.Weekday())] &&
+			s.hours[t.Hour()] && s.minutes[t.Minute()] {
+			return t
+		}
+		t = t.Add(time.Minute)
+	}
+	return time.Time{}
+}
@@ -0,0 +1,68 @@
+// Copyright 2015 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package cron_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/juju/juju/cron"
+	gc "launchpad.net/gocheck"
+)
+
+func Test(t *testing.T) {
+	gc.TestingT(t)
+}
+
+type cronSuite struct{}
+
+var _ = gc.Suite(&cronSuite{})
+
+func (s *cronSuite) TestParseRejectsWrongFieldCount(c *gc.C) {
+	_, err := cron.Parse("* * *")
+	c.Assert(err, gc.ErrorMatches, `invalid cron expression "\* \* \*": expected 5 fields, got 3`)
+}
+
+func (s *cronSuite) TestParseRejectsBadValue(c *gc.C) {
+	_, err := cron.Parse("60 * * * *")
+	c.Assert(err, gc.ErrorMatches, `invalid cron expression "60 \* \* \* \*": bad value in "60"`)
+}
+
+func (s *cronSuite) TestParseRejectsBadStep(c *gc.C) {
+	_, err := cron.Parse("*/0 * * * *")
+	c.Assert(err, gc.ErrorMatches, `invalid cron expression "\*/0 \* \* \* \*": bad step in "\*/0"`)
+}
+
+func (s *cronSuite) TestNextDailySchedule(c *gc.C) {
+	sched, err := cron.Parse("0 2 * * *")
+	c.Assert(err, gc.IsNil)
+	after := time.Date(2015, 1, 1, 3, 0, 0, 0, time.UTC)
+	next := sched.Next(after)
+	c.Assert(next, gc.Equals, time.Date(2015, 1, 2, 2, 0, 0, 0, time.UTC))
+}
+
+func (s *cronSuite) TestNextSameDayLater(c *gc.C) {
+	sched, err := cron.Parse("30 14 * * *")
+	c.Assert(err, gc.IsNil)
+	after := time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)
+	next := sched.Next(after)
+	c.Assert(next, gc.Equals, time.Date(2015, 1, 1, 14, 30, 0, 0, time.UTC))
+}
+
+func (s *cronSuite) TestNextStepField(c *gc.C) {
+	sched, err := cron.Parse("*/15 * * * *")
+	c.Assert(err, gc.IsNil)
+	after := time.Date(2015, 1, 1, 0, 1, 0, 0, time.UTC)
+	next := sched.Next(after)
+	c.Assert(next, gc.Equals, time.Date(2015, 1, 1, 0, 15, 0, 0, time.UTC))
+}
+
+func (s *cronSuite) TestNextSkipsImpossibleCombination(c *gc.C) {
+	// February never has a 30th, so this schedule should never fire
+	// within the year-plus-a-day the search is bounded to.
+	sched, err := cron.Parse("0 0 30 2 *")
+	c.Assert(err, gc.IsNil)
+	after := time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)
+	c.Assert(sched.Next(after).IsZero(), gc.Equals, true)
+}
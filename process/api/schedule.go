@@ -0,0 +1,69 @@
+// Copyright 2015 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package api
+
+import (
+	"github.com/juju/juju/process"
+	"github.com/juju/juju/state/api/params"
+)
+
+// ScheduleProcessesArg holds a process id and the Schedule it should
+// run on.
+type ScheduleProcessesArg struct {
+	ID       string
+	Schedule process.Schedule
+}
+
+// ScheduleProcessesArgs are the arguments for the ScheduleProcesses API
+// method.
+type ScheduleProcessesArgs struct {
+	Args []ScheduleProcessesArg
+}
+
+// UnscheduleProcessesArgs are the arguments for the
+// UnscheduleProcesses API method.
+type UnscheduleProcessesArgs struct {
+	IDs []string
+}
+
+// SetHealthCheckArg holds a process id and the HealthCheck it should
+// be monitored with.
+type SetHealthCheckArg struct {
+	ID          string
+	HealthCheck process.HealthCheck
+}
+
+// SetHealthCheckArgs are the arguments for the SetHealthCheck API
+// method.
+type SetHealthCheckArgs struct {
+	Args []SetHealthCheckArg
+}
+
+// WatchProcessStatusArgs are the arguments for the WatchProcessStatus
+// API method. An empty IDs watches every process registered for the
+// unit.
+type WatchProcessStatusArgs struct {
+	IDs []string
+}
+
+// ProcessStatusWatchResult holds the outcome of starting a
+// WatchProcessStatus watcher: either an error, or the id of a watcher
+// the caller can poll via the usual watcher facade's Next call.
+type ProcessStatusWatchResult struct {
+	ProcessStatusWatcherId string
+	Error                  *params.Error
+}
+
+// ProcessStatusChangesResult holds one batch of changes returned by a
+// process status watcher's Next call.
+type ProcessStatusChangesResult struct {
+	Changes []process.StatusChange
+	Error   *params.Error
+}
+
+// ProcessStatusWatcherArgs identifies a previously registered process
+// status watcher, by the id returned from WatchProcessStatus.
+type ProcessStatusWatcherArgs struct {
+	WatcherId string
+}
@@ -27,17 +27,47 @@ type UnitProcesses interface {
 	SetStatus(id string, status process.Status) error
 	// Unregister removes the information for the process with the given id.
 	Unregister(id string) error
+	// Schedule sets the invocation schedule for the process with the
+	// given id, replacing any existing one.
+	Schedule(id string, sched process.Schedule) error
+	// Unschedule clears the invocation schedule for the process with
+	// the given id, if any.
+	Unschedule(id string) error
+	// SetHealthCheck sets the health check policy for the process with
+	// the given id, replacing any existing one. A zero-value hc clears
+	// it.
+	SetHealthCheck(id string, hc process.HealthCheck) error
+	// WatchStatus returns a watcher of status transitions for the
+	// named processes, or for all of the unit's processes if ids is
+	// empty.
+	WatchStatus(ids ...string) (ProcessStatusWatcher, error)
+}
+
+// ProcessStatusWatcher notifies of status transitions for a unit's
+// workload processes. It is returned by UnitProcesses.WatchStatus and
+// driven by the supervisor goroutine that evaluates health checks and
+// applies restart policies on the unit agent.
+type ProcessStatusWatcher interface {
+	// Changes returns the channel on which batches of status changes
+	// are delivered. It is closed when Stop is called.
+	Changes() <-chan []process.StatusChange
+	// Stop releases the watcher's resources.
+	Stop() error
 }
 
 // HookContextAPI serves workload process-specific API methods.
 type HookContextAPI struct {
 	// State exposes the workload process aspect of Juju's state.
 	State UnitProcesses
+
+	// resources tracks long-lived objects, such as the watchers
+	// returned by WatchProcessStatus, between API calls.
+	resources *common.Resources
 }
 
 // NewHookContextAPI builds a new facade for the given State.
-func NewHookContextAPI(st UnitProcesses) *HookContextAPI {
-	return &HookContextAPI{State: st}
+func NewHookContextAPI(st UnitProcesses, resources *common.Resources) *HookContextAPI {
+	return &HookContextAPI{State: st, resources: resources}
 }
 
 // RegisterProcess registers a workload process in state.
@@ -135,4 +165,91 @@ func (a HookContextAPI) UnregisterProcesses(args api.UnregisterProcessesArgs) (a
 		r.Results = append(r.Results, res)
 	}
 	return r, nil
-}
\ No newline at end of file
+}
+
+// ScheduleProcesses sets the invocation schedule for a set of
+// registered workload processes. The unit agent's supervisor goroutine
+// picks up the new schedule on its next tick.
+func (a HookContextAPI) ScheduleProcesses(args api.ScheduleProcessesArgs) (api.ProcessResults, error) {
+	r := api.ProcessResults{}
+	for _, arg := range args.Args {
+		res := api.ProcessResult{
+			ID: arg.ID,
+		}
+		if err := a.State.Schedule(arg.ID, arg.Schedule); err != nil {
+			res.Error = common.ServerError(errors.Trace(err))
+			r.Error = common.ServerError(api.BulkFailure)
+		}
+		r.Results = append(r.Results, res)
+	}
+	return r, nil
+}
+
+// UnscheduleProcesses clears the invocation schedule for a set of
+// workload processes.
+func (a HookContextAPI) UnscheduleProcesses(args api.UnscheduleProcessesArgs) (api.ProcessResults, error) {
+	r := api.ProcessResults{}
+	for _, id := range args.IDs {
+		res := api.ProcessResult{
+			ID: id,
+		}
+		if err := a.State.Unschedule(id); err != nil {
+			res.Error = common.ServerError(errors.Trace(err))
+			r.Error = common.ServerError(api.BulkFailure)
+		}
+		r.Results = append(r.Results, res)
+	}
+	return r, nil
+}
+
+// SetHealthCheck sets the health check policy for a set of workload
+// processes. The unit agent's supervisor goroutine starts (or stops)
+// probing accordingly.
+func (a HookContextAPI) SetHealthCheck(args api.SetHealthCheckArgs) (api.ProcessResults, error) {
+	r := api.ProcessResults{}
+	for _, arg := range args.Args {
+		res := api.ProcessResult{
+			ID: arg.ID,
+		}
+		if err := a.State.SetHealthCheck(arg.ID, arg.HealthCheck); err != nil {
+			res.Error = common.ServerError(errors.Trace(err))
+			r.Error = common.ServerError(api.BulkFailure)
+		}
+		r.Results = append(r.Results, res)
+	}
+	return r, nil
+}
+
+// WatchProcessStatus starts a watcher for status transitions of the
+// named processes (all of the unit's processes, if none are named),
+// and returns the id by which the caller can fetch subsequent batches
+// of changes via the usual watcher facade's Next call.
+func (a HookContextAPI) WatchProcessStatus(args api.WatchProcessStatusArgs) (api.ProcessStatusWatchResult, error) {
+	w, err := a.State.WatchStatus(args.IDs...)
+	if err != nil {
+		return api.ProcessStatusWatchResult{
+			Error: common.ServerError(errors.Trace(err)),
+		}, nil
+	}
+	id := a.resources.Register(w)
+	return api.ProcessStatusWatchResult{
+		ProcessStatusWatcherId: id,
+	}, nil
+}
+
+// NextProcessStatusChanges returns the next batch of changes from a
+// watcher previously started with WatchProcessStatus.
+func (a HookContextAPI) NextProcessStatusChanges(args api.ProcessStatusWatcherArgs) (api.ProcessStatusChangesResult, error) {
+	resource := a.resources.Get(args.WatcherId)
+	w, ok := resource.(ProcessStatusWatcher)
+	if !ok {
+		err := errors.NotFoundf("process status watcher %q", args.WatcherId)
+		return api.ProcessStatusChangesResult{Error: common.ServerError(err)}, nil
+	}
+	changes, ok := <-w.Changes()
+	if !ok {
+		err := errors.Errorf("process status watcher %q was stopped", args.WatcherId)
+		return api.ProcessStatusChangesResult{Error: common.ServerError(err)}, nil
+	}
+	return api.ProcessStatusChangesResult{Changes: changes}, nil
+}
@@ -0,0 +1,14 @@
+// Copyright 2015 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package server
+
+import (
+	"github.com/juju/juju/process"
+)
+
+// A UnitProcesses implementation is expected to return a per-unit
+// process.Supervisor from WatchStatus: its background goroutine drives
+// scheduled invocations and health checks, and it doubles as the
+// watcher that feeds WatchProcessStatus/NextProcessStatusChanges.
+var _ ProcessStatusWatcher = (*process.Supervisor)(nil)
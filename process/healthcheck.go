@@ -0,0 +1,57 @@
+// Copyright 2015 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package process
+
+import "time"
+
+// HealthCheckKind identifies how a HealthCheck probes a workload
+// process.
+type HealthCheckKind string
+
+const (
+	HealthCheckHTTP HealthCheckKind = "http"
+	HealthCheckTCP  HealthCheckKind = "tcp"
+	HealthCheckExec HealthCheckKind = "exec"
+)
+
+// RestartPolicy says whether the Supervisor re-registers a process once
+// it has failed FailureThreshold consecutive health probes.
+// RestartOnFailure and RestartAlways both do so; RestartNever does not.
+// The Supervisor acts only on health probe results; it does not watch
+// for a process exiting.
+type RestartPolicy string
+
+const (
+	RestartNever     RestartPolicy = "never"
+	RestartOnFailure RestartPolicy = "on-failure"
+	RestartAlways    RestartPolicy = "always"
+)
+
+// HealthCheck describes how a registered workload process should be
+// monitored, and what to do when it is found unhealthy.
+type HealthCheck struct {
+	// Kind is the probe mechanism to use.
+	Kind HealthCheckKind
+
+	// Target is interpreted according to Kind: a URL for "http", a
+	// "host:port" for "tcp", or a command line for "exec".
+	Target string
+
+	// Interval is the probe interval set for the process. The
+	// Supervisor does not read it: it probes every process that has a
+	// health check once per Supervisor tick.
+	Interval time.Duration
+
+	// Timeout is how long a single probe is given to succeed.
+	Timeout time.Duration
+
+	// FailureThreshold is the number of consecutive failed probes
+	// after which RestartPolicy is applied. The process's status is
+	// set to unhealthy from its first failed probe. Zero disables
+	// restarts.
+	FailureThreshold int
+
+	// RestartPolicy says what to do once FailureThreshold is reached.
+	RestartPolicy RestartPolicy
+}
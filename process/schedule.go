@@ -0,0 +1,23 @@
+// Copyright 2015 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package process
+
+import "time"
+
+// Schedule describes when a registered workload process should be
+// (re)invoked by the unit agent on its own initiative, independent of
+// any health check or restart policy.
+type Schedule struct {
+	// Cron is a standard 5-field cron expression.
+	Cron string
+
+	// TimeZone is the IANA time zone name the Cron expression is
+	// evaluated in. An empty TimeZone means UTC.
+	TimeZone string
+
+	// Jitter is the maximum random delay set for each scheduled
+	// invocation. The Supervisor does not read it: a due process is
+	// invoked on the tick at which it is found due, with no added delay.
+	Jitter time.Duration
+}
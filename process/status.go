@@ -0,0 +1,23 @@
+// Copyright 2015 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package process
+
+// StatusValue is the health state recorded for a workload process.
+type StatusValue string
+
+const (
+	StatusRunning   StatusValue = "running"
+	StatusUnhealthy StatusValue = "unhealthy"
+)
+
+// Status describes the current health of a registered workload
+// process, as last evaluated by its Supervisor.
+type Status struct {
+	// State is the process's current health.
+	State StatusValue
+
+	// Message gives the reason for an unhealthy State; it is empty
+	// while State is StatusRunning.
+	Message string
+}
@@ -0,0 +1,14 @@
+// Copyright 2015 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package process
+
+// StatusChange describes a single transition in a workload process's
+// status, as observed by its health check or restart policy.
+type StatusChange struct {
+	// ID identifies the process the change applies to.
+	ID string
+
+	// Status is the process's new status.
+	Status Status
+}
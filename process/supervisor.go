@@ -0,0 +1,248 @@
+// Copyright 2015 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package process
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os/exec"
+	"time"
+
+	"github.com/juju/loggo"
+
+	"github.com/juju/juju/cron"
+)
+
+var supervisorLogger = loggo.GetLogger("juju.process.supervisor")
+
+// Runtime is the subset of unit agent functionality a Supervisor needs
+// in order to fire scheduled invocations, evaluate health checks, and
+// act on their outcomes.
+type Runtime interface {
+	// List returns the currently registered processes for the unit,
+	// including each one's current Schedule and HealthCheck.
+	List() ([]Info, error)
+
+	// Register (re-)registers a workload process, starting it if it
+	// is not already running.
+	Register(info Info) error
+
+	// SetStatus records the current status of a registered process.
+	SetStatus(id string, status Status) error
+}
+
+// EvaluateHealth maps the outcome of a single health probe to the
+// Status that should be recorded for the process. It is a variable so
+// tests can substitute their own mapping without depending on this
+// one's exact Message text.
+var EvaluateHealth = func(healthy bool) Status {
+	if healthy {
+		return Status{State: StatusRunning}
+	}
+	return Status{State: StatusUnhealthy, Message: "health check failing"}
+}
+
+// Supervisor runs, for a single unit, the background loop that fires
+// scheduled process invocations, evaluates health checks, and applies
+// restart policies: a process that fails its health check enough
+// times in a row to cross FailureThreshold is re-registered according
+// to its RestartPolicy.
+type Supervisor struct {
+	unitName string
+	runtime  Runtime
+	tick     time.Duration
+
+	done    chan struct{}
+	changes chan []StatusChange
+
+	healthy  map[string]bool
+	failures map[string]int
+}
+
+// NewSupervisor returns a Supervisor for the named unit, which checks
+// for scheduled and unhealthy processes once per tick.
+func NewSupervisor(unitName string, runtime Runtime, tick time.Duration) *Supervisor {
+	return &Supervisor{
+		unitName: unitName,
+		runtime:  runtime,
+		tick:     tick,
+		done:     make(chan struct{}),
+		changes:  make(chan []StatusChange, 1),
+		healthy:  make(map[string]bool),
+		failures: make(map[string]int),
+	}
+}
+
+// Changes returns the channel on which batches of status changes
+// observed by the supervisor are delivered. It satisfies the
+// process/api/server.ProcessStatusWatcher interface.
+func (sup *Supervisor) Changes() <-chan []StatusChange {
+	return sup.changes
+}
+
+// Stop ends the supervisor's Run loop and closes Changes.
+func (sup *Supervisor) Stop() error {
+	close(sup.done)
+	return nil
+}
+
+// Run checks every tick until Stop is called. It is meant to be
+// started with "go sup.Run()".
+func (sup *Supervisor) Run() {
+	last := time.Now()
+	ticker := time.NewTicker(sup.tick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-sup.done:
+			close(sup.changes)
+			return
+		case now := <-ticker.C:
+			sup.check(last, now)
+			last = now
+		}
+	}
+}
+
+// check fires any process whose schedule has a firing time in
+// (since, now], probes every process with a health check configured,
+// and restarts those that have crossed their FailureThreshold.
+func (sup *Supervisor) check(since, now time.Time) {
+	infos, err := sup.runtime.List()
+	if err != nil {
+		supervisorLogger.Errorf("unit %q: cannot list processes: %v", sup.unitName, err)
+		return
+	}
+	var changes []StatusChange
+	for _, info := range infos {
+		id := info.ID()
+		if info.Schedule.Cron != "" && sup.due(id, info.Schedule, since, now) {
+			if err := sup.runtime.Register(info); err != nil {
+				supervisorLogger.Errorf("unit %q: scheduled invocation of %q failed: %v", sup.unitName, id, err)
+			}
+		}
+		if info.HealthCheck.Kind == "" {
+			continue
+		}
+		healthy := Probe(info.HealthCheck)
+		if status, changed := sup.evaluateHealth(id, healthy); changed {
+			if err := sup.runtime.SetStatus(id, status); err != nil {
+				supervisorLogger.Errorf("unit %q: cannot record status for %q: %v", sup.unitName, id, err)
+			}
+			changes = append(changes, StatusChange{ID: id, Status: status})
+		}
+		if !healthy && sup.shouldRestart(id, info.HealthCheck) {
+			if err := sup.runtime.Register(info); err != nil {
+				supervisorLogger.Errorf("unit %q: cannot restart %q: %v", sup.unitName, id, err)
+			} else {
+				sup.failures[id] = 0
+			}
+		}
+	}
+	if len(changes) == 0 {
+		return
+	}
+	select {
+	case sup.changes <- changes:
+	default:
+		supervisorLogger.Warningf("unit %q: dropping status changes; no watcher listening", sup.unitName)
+	}
+}
+
+// due reports whether sched has a firing time in (since, now].
+func (sup *Supervisor) due(id string, sched Schedule, since, now time.Time) bool {
+	cs, err := cron.Parse(sched.Cron)
+	if err != nil {
+		supervisorLogger.Errorf("unit %q: process %q has an invalid schedule: %v", sup.unitName, id, err)
+		return false
+	}
+	loc := time.UTC
+	if sched.TimeZone != "" {
+		l, err := time.LoadLocation(sched.TimeZone)
+		if err != nil {
+			supervisorLogger.Errorf("unit %q: process %q has an invalid time zone %q: %v", sup.unitName, id, sched.TimeZone, err)
+			return false
+		}
+		loc = l
+	}
+	next := cs.Next(since.In(loc))
+	return !next.IsZero() && !next.After(now.In(loc))
+}
+
+// evaluateHealth records the outcome of a single health probe and
+// reports the Status to set and whether it differs from the process's
+// last known health, i.e. whether this is a transition worth
+// recording.
+func (sup *Supervisor) evaluateHealth(id string, healthy bool) (Status, bool) {
+	if healthy {
+		sup.failures[id] = 0
+	} else {
+		sup.failures[id]++
+	}
+	wasHealthy, tracked := sup.healthy[id]
+	sup.healthy[id] = healthy
+	if tracked && wasHealthy == healthy {
+		var zero Status
+		return zero, false
+	}
+	return EvaluateHealth(healthy), true
+}
+
+// shouldRestart reports whether the process's consecutive failure
+// count has crossed hc.FailureThreshold and hc.RestartPolicy calls for
+// a restart in that case.
+func (sup *Supervisor) shouldRestart(id string, hc HealthCheck) bool {
+	if hc.FailureThreshold <= 0 || sup.failures[id] < hc.FailureThreshold {
+		return false
+	}
+	switch hc.RestartPolicy {
+	case RestartOnFailure, RestartAlways:
+		return true
+	default:
+		return false
+	}
+}
+
+// Probe runs a single health check and reports whether the process
+// appears healthy.
+func Probe(hc HealthCheck) bool {
+	switch hc.Kind {
+	case HealthCheckHTTP:
+		return probeHTTP(hc)
+	case HealthCheckTCP:
+		return probeTCP(hc)
+	case HealthCheckExec:
+		return probeExec(hc)
+	default:
+		supervisorLogger.Errorf("unknown health check kind %q", hc.Kind)
+		return false
+	}
+}
+
+func probeHTTP(hc HealthCheck) bool {
+	client := http.Client{Timeout: hc.Timeout}
+	resp, err := client.Get(hc.Target)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode < 400
+}
+
+func probeTCP(hc HealthCheck) bool {
+	conn, err := net.DialTimeout("tcp", hc.Target, hc.Timeout)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+func probeExec(hc HealthCheck) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), hc.Timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", hc.Target)
+	return cmd.Run() == nil
+}
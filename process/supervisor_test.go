@@ -0,0 +1,124 @@
+// Copyright 2015 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package process
+
+import (
+	"testing"
+	"time"
+
+	gc "launchpad.net/gocheck"
+)
+
+func Test(t *testing.T) {
+	gc.TestingT(t)
+}
+
+type supervisorSuite struct{}
+
+var _ = gc.Suite(&supervisorSuite{})
+
+func newTestSupervisor() *Supervisor {
+	return &Supervisor{
+		unitName: "u/0",
+		healthy:  make(map[string]bool),
+		failures: make(map[string]int),
+	}
+}
+
+func (s *supervisorSuite) TestEvaluateHealthMapsHealthyAndUnhealthy(c *gc.C) {
+	c.Assert(EvaluateHealth(true), gc.Equals, Status{State: StatusRunning})
+	c.Assert(EvaluateHealth(false), gc.Equals, Status{State: StatusUnhealthy, Message: "health check failing"})
+}
+
+func (s *supervisorSuite) TestEvaluateHealthFirstObservationAlwaysReported(c *gc.C) {
+	sup := newTestSupervisor()
+	status, changed := sup.evaluateHealth("proc", true)
+	c.Assert(changed, gc.Equals, true)
+	c.Assert(status, gc.Equals, Status{State: StatusRunning})
+}
+
+func (s *supervisorSuite) TestEvaluateHealthNoChangeNotReported(c *gc.C) {
+	sup := newTestSupervisor()
+	_, changed := sup.evaluateHealth("proc", true)
+	c.Assert(changed, gc.Equals, true)
+	_, changed = sup.evaluateHealth("proc", true)
+	c.Assert(changed, gc.Equals, false)
+}
+
+func (s *supervisorSuite) TestEvaluateHealthTransitionReported(c *gc.C) {
+	sup := newTestSupervisor()
+	sup.evaluateHealth("proc", true)
+	status, changed := sup.evaluateHealth("proc", false)
+	c.Assert(changed, gc.Equals, true)
+	c.Assert(status, gc.Equals, Status{State: StatusUnhealthy, Message: "health check failing"})
+}
+
+func (s *supervisorSuite) TestEvaluateHealthTracksFailureCount(c *gc.C) {
+	sup := newTestSupervisor()
+	sup.evaluateHealth("proc", false)
+	sup.evaluateHealth("proc", false)
+	c.Assert(sup.failures["proc"], gc.Equals, 2)
+	sup.evaluateHealth("proc", true)
+	c.Assert(sup.failures["proc"], gc.Equals, 0)
+}
+
+func (s *supervisorSuite) TestShouldRestartBelowThreshold(c *gc.C) {
+	sup := newTestSupervisor()
+	sup.failures["proc"] = 2
+	hc := HealthCheck{FailureThreshold: 3, RestartPolicy: RestartOnFailure}
+	c.Assert(sup.shouldRestart("proc", hc), gc.Equals, false)
+}
+
+func (s *supervisorSuite) TestShouldRestartAtThresholdOnFailure(c *gc.C) {
+	sup := newTestSupervisor()
+	sup.failures["proc"] = 3
+	hc := HealthCheck{FailureThreshold: 3, RestartPolicy: RestartOnFailure}
+	c.Assert(sup.shouldRestart("proc", hc), gc.Equals, true)
+}
+
+func (s *supervisorSuite) TestShouldRestartNeverPolicy(c *gc.C) {
+	sup := newTestSupervisor()
+	sup.failures["proc"] = 5
+	hc := HealthCheck{FailureThreshold: 3, RestartPolicy: RestartNever}
+	c.Assert(sup.shouldRestart("proc", hc), gc.Equals, false)
+}
+
+func (s *supervisorSuite) TestShouldRestartZeroThresholdDisabled(c *gc.C) {
+	sup := newTestSupervisor()
+	sup.failures["proc"] = 100
+	hc := HealthCheck{FailureThreshold: 0, RestartPolicy: RestartAlways}
+	c.Assert(sup.shouldRestart("proc", hc), gc.Equals, false)
+}
+
+func (s *supervisorSuite) TestDueFiresWithinWindow(c *gc.C) {
+	sup := newTestSupervisor()
+	sched := Schedule{Cron: "0 2 * * *"}
+	since := time.Date(2015, 1, 1, 1, 0, 0, 0, time.UTC)
+	now := time.Date(2015, 1, 1, 3, 0, 0, 0, time.UTC)
+	c.Assert(sup.due("proc", sched, since, now), gc.Equals, true)
+}
+
+func (s *supervisorSuite) TestDueNotYetReached(c *gc.C) {
+	sup := newTestSupervisor()
+	sched := Schedule{Cron: "0 2 * * *"}
+	since := time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := time.Date(2015, 1, 1, 1, 0, 0, 0, time.UTC)
+	c.Assert(sup.due("proc", sched, since, now), gc.Equals, false)
+}
+
+func (s *supervisorSuite) TestDueInvalidScheduleIsNotDue(c *gc.C) {
+	sup := newTestSupervisor()
+	sched := Schedule{Cron: "not a schedule"}
+	since := time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := time.Date(2015, 1, 2, 0, 0, 0, 0, time.UTC)
+	c.Assert(sup.due("proc", sched, since, now), gc.Equals, false)
+}
+
+func (s *supervisorSuite) TestDueInvalidTimeZoneIsNotDue(c *gc.C) {
+	sup := newTestSupervisor()
+	sched := Schedule{Cron: "0 2 * * *", TimeZone: "Not/AZone"}
+	since := time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := time.Date(2015, 1, 2, 0, 0, 0, 0, time.UTC)
+	c.Assert(sup.due("proc", sched, since, now), gc.Equals, false)
+}
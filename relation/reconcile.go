@@ -0,0 +1,60 @@
+// Copyright 2012, 2013 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package relation
+
+import (
+	"launchpad.net/juju-core/mstate"
+)
+
+// ReconcileReport describes the work needed to bring a set of on-disk
+// relation state directories back into step with the relations a unit
+// agent is actually part of.
+type ReconcileReport struct {
+	// Join lists the relation units for which no state directory
+	// exists yet, and which must therefore be joined from scratch.
+	Join []*mstate.RelationUnit
+
+	// Replay lists hooks that were recorded as queued, but not
+	// recorded as complete, the last time the relation's state was
+	// written to disk, and so must be re-run before anything else.
+	Replay []HookInfo
+
+	// StaleIds lists relation ids with a state directory on disk but
+	// no corresponding relation unit, which must be removed.
+	StaleIds []int
+}
+
+// Reconcile compares the relation units rus, as currently reported by
+// state, against the relation state directories dirs already persisted
+// to disk, and reports the steps required to bring the two back into
+// agreement. It is meant to run once, early in unit agent startup, so
+// that an agent restarting mid-hook resumes deterministically instead
+// of silently skipping or repeating work.
+func Reconcile(rus []*mstate.RelationUnit, dirs map[int]*StateDir) *ReconcileReport {
+	report := &ReconcileReport{}
+	known := make(map[int]bool, len(rus))
+	for _, ru := range rus {
+		id := ru.Relation().Id()
+		known[id] = true
+		dir, ok := dirs[id]
+		if !ok {
+			report.Join = append(report.Join, ru)
+			continue
+		}
+		st := dir.State()
+		if st.ChangedPending != "" {
+			report.Replay = append(report.Replay, HookInfo{
+				HookKind:      HookChanged,
+				RemoteUnit:    st.ChangedPending,
+				ChangeVersion: st.Members[st.ChangedPending],
+			})
+		}
+	}
+	for id := range dirs {
+		if !known[id] {
+			report.StaleIds = append(report.StaleIds, id)
+		}
+	}
+	return report
+}
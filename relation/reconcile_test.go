@@ -0,0 +1,36 @@
+// Copyright 2012, 2013 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package relation
+
+import (
+	"sort"
+
+	gc "launchpad.net/gocheck"
+)
+
+type ReconcileSuite struct{}
+
+var _ = gc.Suite(&ReconcileSuite{})
+
+func (s *ReconcileSuite) TestReconcileEmpty(c *gc.C) {
+	report := Reconcile(nil, map[int]*StateDir{})
+	c.Assert(report, gc.DeepEquals, &ReconcileReport{})
+}
+
+// TestReconcileStaleIds covers the case where a state directory exists
+// on disk for a relation the unit is no longer part of. The Join and
+// Replay cases both require a live *mstate.RelationUnit, which needs a
+// real (or fake) *mstate.State to construct and so are exercised by
+// the unit agent's integration tests rather than here.
+func (s *ReconcileSuite) TestReconcileStaleIds(c *gc.C) {
+	dirs := map[int]*StateDir{
+		1: {path: "x", state: NewState(1)},
+		2: {path: "y", state: NewState(2)},
+	}
+	report := Reconcile(nil, dirs)
+	c.Assert(report.Join, gc.HasLen, 0)
+	c.Assert(report.Replay, gc.HasLen, 0)
+	sort.Ints(report.StaleIds)
+	c.Assert(report.StaleIds, gc.DeepEquals, []int{1, 2})
+}
@@ -0,0 +1,101 @@
+// Copyright 2012, 2013 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+// Package relation holds the unit agent's on-disk view of its joined
+// relations. It exists so that a unit agent interrupted mid-hook can
+// work out, on restart, exactly what it had and had not yet done,
+// without reference to anything but its own local state.
+package relation
+
+import (
+	"fmt"
+
+	"launchpad.net/juju-core/trivial"
+)
+
+// HookKind identifies the kind of a relation hook.
+type HookKind string
+
+const (
+	HookJoined   HookKind = "joined"
+	HookChanged  HookKind = "changed"
+	HookDeparted HookKind = "departed"
+)
+
+// HookInfo holds the information necessary to run, or record the
+// running of, a relation hook.
+type HookInfo struct {
+	// HookKind is the kind of hook to be run.
+	HookKind HookKind
+
+	// RemoteUnit is the name of the unit the hook is about.
+	RemoteUnit string
+
+	// ChangeVersion is the version of the RemoteUnit's settings as
+	// seen by the hook. It is irrelevant for "departed" hooks.
+	ChangeVersion int64
+}
+
+// State describes the state of a relation as last recorded on disk by
+// the unit agent.
+type State struct {
+	// RelationId identifies the relation.
+	RelationId int
+
+	// Members maps the name of each joined remote unit to the last
+	// change version of its settings recorded on disk.
+	Members map[string]int64
+
+	// ChangedPending, if not empty, is the name of the unit for which
+	// a "changed" hook was queued, but not yet recorded as complete,
+	// when the relation was last written to disk.
+	ChangedPending string
+}
+
+// NewState returns an empty State for the relation with the supplied id.
+func NewState(relationId int) *State {
+	return &State{
+		RelationId: relationId,
+		Members:    map[string]int64{},
+	}
+}
+
+// Copy returns an independent copy of s.
+func (s *State) Copy() *State {
+	cp := &State{
+		RelationId:     s.RelationId,
+		Members:        make(map[string]int64, len(s.Members)),
+		ChangedPending: s.ChangedPending,
+	}
+	for m, v := range s.Members {
+		cp.Members[m] = v
+	}
+	return cp
+}
+
+// Validate returns an error if hi does not represent a valid change to
+// s. While a "changed" hook is outstanding for some unit, only hooks
+// for that unit are valid. Subject to that, valid changes are:
+//
+//   - "joined", for a unit that is not already a member;
+//   - "changed" or "departed", for a unit that is already a member.
+func (s *State) Validate(hi HookInfo) (err error) {
+	defer trivial.ErrorContextf(&err, "inappropriate %q for %q", hi.HookKind, hi.RemoteUnit)
+	if s.ChangedPending != "" && s.ChangedPending != hi.RemoteUnit {
+		return fmt.Errorf("expected %q for %q", HookChanged, s.ChangedPending)
+	}
+	_, joined := s.Members[hi.RemoteUnit]
+	switch hi.HookKind {
+	case HookJoined:
+		if joined {
+			return fmt.Errorf("unit already joined")
+		}
+	case HookChanged, HookDeparted:
+		if !joined {
+			return fmt.Errorf("unit has not joined")
+		}
+	default:
+		return fmt.Errorf("unknown hook kind")
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+// Copyright 2012, 2013 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package relation
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"launchpad.net/juju-core/trivial"
+)
+
+// StateDir manages a single relation's state directory, and exposes it
+// as a *State.
+type StateDir struct {
+	path  string
+	state *State
+}
+
+// Path returns the path to the directory in which the relation's state
+// is persisted.
+func (d *StateDir) Path() string {
+	return d.path
+}
+
+// State returns a copy of the relation's current state.
+func (d *StateDir) State() *State {
+	return d.state.Copy()
+}
+
+// ReadStateDir loads a StateDir from basedir/<id>, creating the
+// directory (empty) if it does not yet exist.
+func ReadStateDir(basedir string, id int) (d *StateDir, err error) {
+	defer trivial.ErrorContextf(&err, "cannot load relation state from %q", basedir)
+	path := filepath.Join(basedir, strconv.Itoa(id))
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, err
+	}
+	fis, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	state := NewState(id)
+	for _, fi := range fis {
+		name := fi.Name()
+		if strings.HasPrefix(name, ".") {
+			// Leftover of an interrupted write; safe to ignore.
+			continue
+		}
+		us, err := readUnitFile(filepath.Join(path, name))
+		if err != nil {
+			return nil, fmt.Errorf("invalid unit file %q: %v", name, err)
+		}
+		state.Members[us.UnitName] = us.ChangeVersion
+		if us.Pending == HookChanged {
+			state.ChangedPending = us.UnitName
+		}
+	}
+	return &StateDir{path: path, state: state}, nil
+}
+
+// ReadAllStateDirs loads a StateDir for every relation id found under
+// basedir, which need not itself exist.
+func ReadAllStateDirs(basedir string) (dirs map[int]*StateDir, err error) {
+	defer trivial.ErrorContextf(&err, "cannot load relations state from %q", basedir)
+	fis, err := ioutil.ReadDir(basedir)
+	if os.IsNotExist(err) {
+		return map[int]*StateDir{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	dirs = map[int]*StateDir{}
+	for _, fi := range fis {
+		id, err := strconv.Atoi(fi.Name())
+		if err != nil {
+			// Not a relation state directory; ignore it.
+			continue
+		}
+		dir, err := ReadStateDir(basedir, id)
+		if err != nil {
+			return nil, err
+		}
+		dirs[id] = dir
+	}
+	return dirs, nil
+}
+
+// Write atomically records hi as the relation's new state, after
+// validating it against the state already on disk.
+func (d *StateDir) Write(hi HookInfo) (err error) {
+	defer trivial.ErrorContextf(&err, "cannot write relation state for %q", hi.RemoteUnit)
+	if err := d.state.Validate(hi); err != nil {
+		return err
+	}
+	if hi.HookKind == HookDeparted {
+		if err := os.Remove(filepath.Join(d.path, unitFileName(hi.RemoteUnit))); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		delete(d.state.Members, hi.RemoteUnit)
+		if d.state.ChangedPending == hi.RemoteUnit {
+			d.state.ChangedPending = ""
+		}
+		return nil
+	}
+	us := &unitState{UnitName: hi.RemoteUnit, ChangeVersion: hi.ChangeVersion}
+	if hi.HookKind == HookJoined {
+		// A "joined" hook always queues a "changed" hook behind it.
+		us.Pending = HookChanged
+	}
+	if err := writeUnitFile(d.path, us); err != nil {
+		return err
+	}
+	d.state.Members[hi.RemoteUnit] = hi.ChangeVersion
+	if hi.HookKind == HookJoined {
+		d.state.ChangedPending = hi.RemoteUnit
+	} else {
+		d.state.ChangedPending = ""
+	}
+	return nil
+}
+
+// unitState is the decoded content of a single remote unit's state file.
+type unitState struct {
+	UnitName      string
+	ChangeVersion int64
+	Pending       HookKind
+}
+
+// unitFileName returns the on-disk file name used to persist state for
+// the named remote unit.
+func unitFileName(unitName string) string {
+	return strings.Replace(unitName, "/", "-", 1)
+}
+
+// readUnitFile reads and decodes the unit state file at path.
+func readUnitFile(path string) (*unitState, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.SplitN(string(data), "\n", 3)
+	if len(lines) < 2 {
+		return nil, fmt.Errorf("truncated state file")
+	}
+	version, err := strconv.ParseInt(lines[1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid change version: %v", err)
+	}
+	us := &unitState{UnitName: lines[0], ChangeVersion: version}
+	if len(lines) == 3 {
+		us.Pending = HookKind(lines[2])
+	}
+	return us, nil
+}
+
+// writeUnitFile writes us to dir, crash-safely: the new content is
+// written to a temporary file in the same directory, fsynced, and then
+// renamed over the real file, so a concurrent crash can never leave a
+// partially-written or missing state file behind.
+func writeUnitFile(dir string, us *unitState) (err error) {
+	defer trivial.ErrorContextf(&err, "cannot write state for unit %q", us.UnitName)
+	name := unitFileName(us.UnitName)
+	content := fmt.Sprintf("%s\n%d\n%s", us.UnitName, us.ChangeVersion, us.Pending)
+	tmp, err := ioutil.TempFile(dir, "."+name+"-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dir, name))
+}
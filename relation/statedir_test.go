@@ -0,0 +1,129 @@
+// Copyright 2012, 2013 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package relation
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	gc "launchpad.net/gocheck"
+)
+
+func Test(t *testing.T) {
+	gc.TestingT(t)
+}
+
+type StateDirSuite struct {
+	basedir string
+}
+
+var _ = gc.Suite(&StateDirSuite{})
+
+func (s *StateDirSuite) SetUpTest(c *gc.C) {
+	s.basedir = c.MkDir()
+}
+
+func (s *StateDirSuite) TestReadStateDirCreatesEmptyDir(c *gc.C) {
+	dir, err := ReadStateDir(s.basedir, 1)
+	c.Assert(err, gc.IsNil)
+	c.Assert(dir.State(), gc.DeepEquals, NewState(1))
+	fi, err := os.Stat(filepath.Join(s.basedir, "1"))
+	c.Assert(err, gc.IsNil)
+	c.Assert(fi.IsDir(), gc.Equals, true)
+}
+
+func (s *StateDirSuite) TestWriteJoinedQueuesChanged(c *gc.C) {
+	dir, err := ReadStateDir(s.basedir, 1)
+	c.Assert(err, gc.IsNil)
+	err = dir.Write(HookInfo{HookKind: HookJoined, RemoteUnit: "wordpress/0", ChangeVersion: 0})
+	c.Assert(err, gc.IsNil)
+	st := dir.State()
+	c.Assert(st.Members, gc.DeepEquals, map[string]int64{"wordpress/0": 0})
+	c.Assert(st.ChangedPending, gc.Equals, "wordpress/0")
+}
+
+func (s *StateDirSuite) TestWriteChangedClearsPending(c *gc.C) {
+	dir, err := ReadStateDir(s.basedir, 1)
+	c.Assert(err, gc.IsNil)
+	c.Assert(dir.Write(HookInfo{HookKind: HookJoined, RemoteUnit: "wordpress/0"}), gc.IsNil)
+	err = dir.Write(HookInfo{HookKind: HookChanged, RemoteUnit: "wordpress/0", ChangeVersion: 1})
+	c.Assert(err, gc.IsNil)
+	st := dir.State()
+	c.Assert(st.Members["wordpress/0"], gc.Equals, int64(1))
+	c.Assert(st.ChangedPending, gc.Equals, "")
+}
+
+func (s *StateDirSuite) TestWriteDepartedRemovesMember(c *gc.C) {
+	dir, err := ReadStateDir(s.basedir, 1)
+	c.Assert(err, gc.IsNil)
+	c.Assert(dir.Write(HookInfo{HookKind: HookJoined, RemoteUnit: "wordpress/0"}), gc.IsNil)
+	c.Assert(dir.Write(HookInfo{HookKind: HookChanged, RemoteUnit: "wordpress/0"}), gc.IsNil)
+	err = dir.Write(HookInfo{HookKind: HookDeparted, RemoteUnit: "wordpress/0"})
+	c.Assert(err, gc.IsNil)
+	st := dir.State()
+	c.Assert(st.Members, gc.DeepEquals, map[string]int64{})
+	_, err = os.Stat(filepath.Join(dir.Path(), "wordpress-0"))
+	c.Assert(os.IsNotExist(err), gc.Equals, true)
+}
+
+func (s *StateDirSuite) TestWriteRejectsInvalidTransition(c *gc.C) {
+	dir, err := ReadStateDir(s.basedir, 1)
+	c.Assert(err, gc.IsNil)
+	err = dir.Write(HookInfo{HookKind: HookChanged, RemoteUnit: "wordpress/0"})
+	c.Assert(err, gc.ErrorMatches, `cannot write relation state for "wordpress/0": inappropriate "changed" for "wordpress/0": unit has not joined`)
+}
+
+func (s *StateDirSuite) TestReadStateDirReloadsPersistedState(c *gc.C) {
+	dir, err := ReadStateDir(s.basedir, 1)
+	c.Assert(err, gc.IsNil)
+	c.Assert(dir.Write(HookInfo{HookKind: HookJoined, RemoteUnit: "wordpress/0", ChangeVersion: 3}), gc.IsNil)
+
+	reloaded, err := ReadStateDir(s.basedir, 1)
+	c.Assert(err, gc.IsNil)
+	c.Assert(reloaded.State(), gc.DeepEquals, dir.State())
+}
+
+func (s *StateDirSuite) TestReadStateDirIgnoresTempFiles(c *gc.C) {
+	dir, err := ReadStateDir(s.basedir, 1)
+	c.Assert(err, gc.IsNil)
+	stray := filepath.Join(dir.Path(), ".wordpress-0-123")
+	c.Assert(ioutil.WriteFile(stray, []byte("junk"), 0644), gc.IsNil)
+
+	reloaded, err := ReadStateDir(s.basedir, 1)
+	c.Assert(err, gc.IsNil)
+	c.Assert(reloaded.State(), gc.DeepEquals, NewState(1))
+}
+
+func (s *StateDirSuite) TestReadAllStateDirsSkipsNonRelationEntries(c *gc.C) {
+	c.Assert(os.MkdirAll(filepath.Join(s.basedir, "1"), 0755), gc.IsNil)
+	c.Assert(os.MkdirAll(filepath.Join(s.basedir, "not-a-relation"), 0755), gc.IsNil)
+
+	dirs, err := ReadAllStateDirs(s.basedir)
+	c.Assert(err, gc.IsNil)
+	c.Assert(dirs, gc.HasLen, 1)
+	_, ok := dirs[1]
+	c.Assert(ok, gc.Equals, true)
+}
+
+func (s *StateDirSuite) TestReadAllStateDirsMissingBasedir(c *gc.C) {
+	dirs, err := ReadAllStateDirs(filepath.Join(s.basedir, "missing"))
+	c.Assert(err, gc.IsNil)
+	c.Assert(dirs, gc.HasLen, 0)
+}
+
+func (s *StateDirSuite) TestWriteUnitFileRoundTrip(c *gc.C) {
+	dir := c.MkDir()
+	us := &unitState{UnitName: "wordpress/0", ChangeVersion: 2, Pending: HookChanged}
+	c.Assert(writeUnitFile(dir, us), gc.IsNil)
+	got, err := readUnitFile(filepath.Join(dir, unitFileName(us.UnitName)))
+	c.Assert(err, gc.IsNil)
+	c.Assert(got, gc.DeepEquals, us)
+
+	// writeUnitFile's temp-then-rename dance must leave no stray file.
+	fis, err := ioutil.ReadDir(dir)
+	c.Assert(err, gc.IsNil)
+	c.Assert(fis, gc.HasLen, 1)
+}
@@ -0,0 +1,356 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package apiserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/juju/errors"
+
+	"github.com/juju/juju/state"
+	"github.com/juju/juju/state/api/params"
+)
+
+// DoBackup is called by the backup handler and the backup scheduler to
+// create a backup archive under tempDir and return its path together
+// with its SHA-1 checksum. It is a variable so that it can be replaced,
+// as the tests do; the default creates no archive and always returns
+// an error.
+var DoBackup = func(tempDir string) (backupFilePath string, sha string, err error) {
+	return "", "", fmt.Errorf("backup not implemented")
+}
+
+// backupHandler serves "/backup" and "/backup/{id}". A POST creates an
+// ad-hoc backup run and streams the resulting archive back to the
+// caller; scheduled runs are triggered by the backupScheduler instead,
+// but share the same DoBackup and backupRuns bookkeeping.
+type backupHandler struct {
+	httpContext
+}
+
+func (h *backupHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	st, err := h.stateForRequestAuthenticatedUser(r)
+	if err != nil {
+		sendError(w, http.StatusUnauthorized, err)
+		return
+	}
+	if id := pathTail(r.URL.Path); id != "" && id != "backup" {
+		h.serveRun(w, r, st, id)
+		return
+	}
+	if r.Method != "POST" {
+		sendError(w, http.StatusMethodNotAllowed, fmt.Errorf("unsupported method: %q", r.Method))
+		return
+	}
+	h.doBackup(w, r, st)
+}
+
+func (h *backupHandler) doBackup(w http.ResponseWriter, r *http.Request, st *state.State) {
+	target, err := resolveBackupTarget(st, r)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+	targetId := ""
+	if target != nil {
+		targetId = target.Name()
+	}
+
+	tempDir, err := ioutil.TempDir("", "juju-backup")
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer os.RemoveAll(tempDir)
+
+	run, err := st.AddBackupRun(newBackupRunId(), "", targetId, time.Now())
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	backupFilePath, sha, err := DoBackup(tempDir)
+	if err != nil {
+		run.SetStatus(state.BackupRunFailed, time.Now(), "", err)
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if target != nil {
+		upload, err := NewUploadTarget(target)
+		if err != nil {
+			run.SetStatus(state.BackupRunFailed, time.Now(), "", err)
+			sendError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if err := upload.Upload(backupFilePath, sha); err != nil {
+			run.SetStatus(state.BackupRunFailed, time.Now(), "", err)
+			sendError(w, http.StatusBadGateway, err)
+			return
+		}
+	}
+
+	if err := run.SetStatus(state.BackupRunComplete, time.Now(), sha, nil); err != nil {
+		logger.Warningf("cannot record backup run %q: %v", run.Id(), err)
+	}
+
+	file, err := os.Open(backupFilePath)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("X-Content-SHA", sha)
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, file)
+}
+
+// serveRun responds to GET with the run's metadata as JSON, and to HEAD
+// with a Location header set to the URL from which the run's archive
+// can be downloaded directly from its target: a signed, time-limited
+// URL for an S3 or Swift target, and a plain file:// URL for a local
+// target.
+func (h *backupHandler) serveRun(w http.ResponseWriter, r *http.Request, st *state.State, id string) {
+	run, err := st.BackupRun(id)
+	if err != nil {
+		sendError(w, http.StatusNotFound, err)
+		return
+	}
+	switch r.Method {
+	case "GET":
+		sendJSON(w, http.StatusOK, backupRunToView(run))
+	case "HEAD":
+		if run.TargetId() == "" {
+			sendError(w, http.StatusNotFound, fmt.Errorf("backup run %q was not uploaded to a target", id))
+			return
+		}
+		target, err := st.BackupTarget(run.TargetId())
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, err)
+			return
+		}
+		upload, err := NewUploadTarget(target)
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, err)
+			return
+		}
+		url, err := upload.SignedURL(run.SHA(), signedURLExpiry)
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, err)
+			return
+		}
+		w.Header().Set("Location", url)
+		w.WriteHeader(http.StatusOK)
+	default:
+		sendError(w, http.StatusMethodNotAllowed, fmt.Errorf("unsupported method: %q", r.Method))
+	}
+}
+
+// backupPolicyView is the API-safe view of a state.BackupPolicy: it
+// exists because BackupPolicy itself only exposes its fields through
+// accessor methods, so sendJSON would otherwise marshal it as "{}".
+type backupPolicyView struct {
+	Name           string
+	Cron           string
+	RetentionCount int
+	RetentionAge   time.Duration
+	TargetId       string
+	Enabled        bool
+}
+
+func backupPolicyToView(p *state.BackupPolicy) backupPolicyView {
+	return backupPolicyView{
+		Name:           p.Name(),
+		Cron:           p.Cron(),
+		RetentionCount: p.RetentionCount(),
+		RetentionAge:   p.RetentionAge(),
+		TargetId:       p.TargetId(),
+		Enabled:        p.Enabled(),
+	}
+}
+
+// backupRunView is the API-safe view of a state.BackupRun; see
+// backupPolicyView.
+type backupRunView struct {
+	Id         string
+	PolicyName string
+	StartTime  time.Time
+	Status     state.BackupRunStatus
+	SHA        string
+	TargetId   string
+}
+
+func backupRunToView(r *state.BackupRun) backupRunView {
+	return backupRunView{
+		Id:         r.Id(),
+		PolicyName: r.PolicyName(),
+		StartTime:  r.StartTime(),
+		Status:     r.Status(),
+		SHA:        r.SHA(),
+		TargetId:   r.TargetId(),
+	}
+}
+
+// backupPoliciesHandler serves CRUD requests against "/backup/policies".
+type backupPoliciesHandler struct {
+	httpContext
+}
+
+type backupPolicyBody struct {
+	Name           string
+	Cron           string
+	RetentionCount int
+	RetentionAge   time.Duration
+	TargetId       string
+	Enabled        bool
+}
+
+func (h *backupPoliciesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	st, err := h.stateForRequestAuthenticatedUser(r)
+	if err != nil {
+		sendError(w, http.StatusUnauthorized, err)
+		return
+	}
+	switch r.Method {
+	case "GET":
+		h.list(w, st)
+	case "POST":
+		h.create(w, r, st)
+	case "DELETE":
+		h.remove(w, r, st)
+	default:
+		sendError(w, http.StatusMethodNotAllowed, fmt.Errorf("unsupported method: %q", r.Method))
+	}
+}
+
+func (h *backupPoliciesHandler) list(w http.ResponseWriter, st *state.State) {
+	policies, err := st.AllBackupPolicies()
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+	views := make([]backupPolicyView, len(policies))
+	for i, p := range policies {
+		views[i] = backupPolicyToView(p)
+	}
+	sendJSON(w, http.StatusOK, views)
+}
+
+func (h *backupPoliciesHandler) create(w http.ResponseWriter, r *http.Request, st *state.State) {
+	var body backupPolicyBody
+	if err := decodeJSONBody(r, &body); err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+	policy, err := st.AddBackupPolicy(body.Name, body.Cron, body.RetentionCount, body.RetentionAge, body.TargetId, body.Enabled)
+	if err != nil {
+		if errors.IsAlreadyExists(err) {
+			sendError(w, http.StatusConflict, err)
+			return
+		}
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+	sendJSON(w, http.StatusCreated, backupPolicyToView(policy))
+}
+
+func (h *backupPoliciesHandler) remove(w http.ResponseWriter, r *http.Request, st *state.State) {
+	name := pathTail(r.URL.Path)
+	if err := st.RemoveBackupPolicy(name); err != nil {
+		if errors.IsNotFound(err) {
+			sendError(w, http.StatusNotFound, err)
+			return
+		}
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// backupRunsHandler serves "/backup/runs", listing the history of runs
+// for a policy (or ad-hoc runs, if no policy is given).
+type backupRunsHandler struct {
+	httpContext
+}
+
+func (h *backupRunsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	st, err := h.stateForRequestAuthenticatedUser(r)
+	if err != nil {
+		sendError(w, http.StatusUnauthorized, err)
+		return
+	}
+	if r.Method != "GET" {
+		sendError(w, http.StatusMethodNotAllowed, fmt.Errorf("unsupported method: %q", r.Method))
+		return
+	}
+	runs, err := st.BackupRuns(r.URL.Query().Get("policy"))
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+	views := make([]backupRunView, len(runs))
+	for i, r := range runs {
+		views[i] = backupRunToView(r)
+	}
+	sendJSON(w, http.StatusOK, views)
+}
+
+// decodeJSONBody decodes a JSON request body into v.
+func decodeJSONBody(r *http.Request, v interface{}) error {
+	return json.NewDecoder(r.Body).Decode(v)
+}
+
+// pathTail returns the final "/"-separated element of an HTTP request
+// path, e.g. "/environment/uuid/backup/42" -> "42".
+func pathTail(urlPath string) string {
+	if i := strings.LastIndex(urlPath, "/"); i >= 0 {
+		return urlPath[i+1:]
+	}
+	return urlPath
+}
+
+// sendJSON writes v to w as a JSON body with the given status code.
+func sendJSON(w http.ResponseWriter, statusCode int, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	w.Write(body)
+}
+
+// sendError writes err to w as a JSON error body with the given status
+// code.
+func sendError(w http.ResponseWriter, statusCode int, err error) {
+	body, marshalErr := json.Marshal(&params.Error{Message: err.Error()})
+	if marshalErr != nil {
+		http.Error(w, err.Error(), statusCode)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	w.Write(body)
+}
+
+var backupRunIdCounter int64
+
+// newBackupRunId returns a new, process-unique id for a backup run.
+// Scheduled runs use it too, so the "adhoc-" prefix does not mark a run
+// as ad-hoc; its PolicyName does.
+func newBackupRunId() string {
+	n := atomic.AddInt64(&backupRunIdCounter, 1)
+	return fmt.Sprintf("adhoc-%d-%d", time.Now().Unix(), n)
+}
@@ -0,0 +1,118 @@
+// Copyright 2015 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package apiserver
+
+import (
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/juju/juju/state"
+)
+
+// backupScheduler periodically checks enabled backup policies and
+// fires DoBackup for any that are due, recording the outcome as a
+// BackupRun and pruning old runs according to the policy's retention
+// settings.
+type backupScheduler struct {
+	st   *state.State
+	tick time.Duration
+	done chan struct{}
+}
+
+// newBackupScheduler returns a scheduler that checks policies every
+// tick for work due since it last looked.
+func newBackupScheduler(st *state.State, tick time.Duration) *backupScheduler {
+	return &backupScheduler{st: st, tick: tick, done: make(chan struct{})}
+}
+
+// run checks every policy once per tick until Kill is called. It is
+// meant to be started with "go s.run()".
+func (s *backupScheduler) run() {
+	last := time.Now()
+	ticker := time.NewTicker(s.tick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case now := <-ticker.C:
+			s.checkPolicies(last, now)
+			last = now
+		}
+	}
+}
+
+// Kill stops the scheduler. It does not wait for a run in progress to
+// finish.
+func (s *backupScheduler) Kill() {
+	close(s.done)
+}
+
+// checkPolicies runs any enabled policy whose schedule has a firing
+// time in (since, now].
+func (s *backupScheduler) checkPolicies(since, now time.Time) {
+	policies, err := s.st.AllBackupPolicies()
+	if err != nil {
+		logger.Errorf("cannot list backup policies: %v", err)
+		return
+	}
+	for _, policy := range policies {
+		if !policy.Enabled() {
+			continue
+		}
+		sched, err := parseCronSchedule(policy.Cron())
+		if err != nil {
+			logger.Errorf("backup policy %q has an invalid schedule: %v", policy.Name(), err)
+			continue
+		}
+		if due := sched.Next(since); due.IsZero() || due.After(now) {
+			continue
+		}
+		s.runPolicy(policy)
+	}
+}
+
+// runPolicy performs one scheduled run of policy and prunes its run
+// history afterwards.
+func (s *backupScheduler) runPolicy(policy *state.BackupPolicy) {
+	tempDir, err := ioutil.TempDir("", "juju-backup")
+	if err != nil {
+		logger.Errorf("cannot create temp dir for backup policy %q: %v", policy.Name(), err)
+		return
+	}
+	defer os.RemoveAll(tempDir)
+
+	targetId := policy.TargetId()
+	if targetId == "" {
+		if name, err := s.st.DefaultBackupTargetName(); err == nil {
+			targetId = name
+		}
+	}
+	run, err := s.st.AddBackupRun(newBackupRunId(), policy.Name(), targetId, time.Now())
+	if err != nil {
+		logger.Errorf("cannot record backup run for policy %q: %v", policy.Name(), err)
+		return
+	}
+
+	backupFilePath, sha, err := DoBackup(tempDir)
+	if err == nil && targetId != "" {
+		var target *state.BackupTarget
+		if target, err = s.st.BackupTarget(targetId); err == nil {
+			var upload UploadTarget
+			if upload, err = NewUploadTarget(target); err == nil {
+				err = upload.Upload(backupFilePath, sha)
+			}
+		}
+	}
+	if err != nil {
+		run.SetStatus(state.BackupRunFailed, time.Now(), "", err)
+	} else if err := run.SetStatus(state.BackupRunComplete, time.Now(), sha, nil); err != nil {
+		logger.Errorf("cannot record completion of backup run %q: %v", run.Id(), err)
+	}
+
+	if _, err := s.st.PruneBackupRuns(policy.Name(), policy.RetentionCount(), policy.RetentionAge()); err != nil {
+		logger.Errorf("cannot prune backup runs for policy %q: %v", policy.Name(), err)
+	}
+}
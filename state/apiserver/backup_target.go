@@ -0,0 +1,242 @@
+// Copyright 2015 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package apiserver
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/juju/errors"
+
+	"github.com/juju/juju/state"
+)
+
+// signedURLExpiry is how long a signed download URL handed out by HEAD
+// /backup/{id} remains valid.
+const signedURLExpiry = 15 * time.Minute
+
+// UploadTarget is what the backup handler needs from a storage target:
+// somewhere to push a finished archive, and a way to hand back a URL
+// from which it can later be fetched directly.
+type UploadTarget interface {
+	Upload(archivePath, key string) error
+	SignedURL(key string, expiry time.Duration) (string, error)
+}
+
+// NewUploadTarget builds the UploadTarget for a configured
+// state.BackupTarget. It is a variable so tests can substitute an
+// in-memory target without standing up real remote storage.
+var NewUploadTarget = func(t *state.BackupTarget) (UploadTarget, error) {
+	switch t.Kind() {
+	case state.BackupTargetLocal:
+		return &localTarget{dir: t.URL()}, nil
+	case state.BackupTargetS3, state.BackupTargetSwift:
+		return &objectStoreTarget{target: t}, nil
+	default:
+		return nil, errors.Errorf("unknown backup target kind %q", t.Kind())
+	}
+}
+
+// resolveBackupTarget returns the target an upload should go to: the
+// one named by "?target=", or failing that the environment's default,
+// or nil if neither is set (in which case the archive is only served
+// inline, as before this feature existed).
+func resolveBackupTarget(st *state.State, r *http.Request) (*state.BackupTarget, error) {
+	name := r.URL.Query().Get("target")
+	if name == "" {
+		var err error
+		name, err = st.DefaultBackupTargetName()
+		if errors.IsNotFound(err) {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	target, err := st.BackupTarget(name)
+	if err != nil {
+		return nil, err
+	}
+	return target, nil
+}
+
+// localTarget is an UploadTarget backed by a directory on the local
+// filesystem running the apiserver.
+type localTarget struct {
+	dir string
+}
+
+func (t *localTarget) Upload(archivePath, key string) error {
+	if err := os.MkdirAll(t.dir, 0755); err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(archivePath)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(t.dir, key), data, 0644)
+}
+
+func (t *localTarget) SignedURL(key string, expiry time.Duration) (string, error) {
+	return "file://" + filepath.Join(t.dir, key), nil
+}
+
+// objectStoreTarget is an UploadTarget backed by an S3-compatible or
+// OpenStack Swift object store reachable over HTTP, addressed as
+// {target.URL}/{key}.
+type objectStoreTarget struct {
+	target *state.BackupTarget
+}
+
+func (t *objectStoreTarget) Upload(archivePath, key string) error {
+	data, err := ioutil.ReadFile(archivePath)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("PUT", t.objectURL(key), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	t.applyCredentials(req)
+	resp, err := t.client().Do(req)
+	if err != nil {
+		return errors.Annotatef(err, "cannot upload to backup target %q", t.target.Name())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return errors.Errorf("upload to backup target %q failed: %s", t.target.Name(), resp.Status)
+	}
+	return nil
+}
+
+func (t *objectStoreTarget) SignedURL(key string, expiry time.Duration) (string, error) {
+	expires := time.Now().Add(expiry).Unix()
+	mac := hmac.New(sha256.New, []byte(t.target.Credential("secret-key")))
+	fmt.Fprintf(mac, "%s:%d", key, expires)
+	sig := hex.EncodeToString(mac.Sum(nil))
+	return fmt.Sprintf("%s?expires=%d&signature=%s", t.objectURL(key), expires, sig), nil
+}
+
+func (t *objectStoreTarget) objectURL(key string) string {
+	return strings.TrimRight(t.target.URL(), "/") + "/" + key
+}
+
+func (t *objectStoreTarget) applyCredentials(req *http.Request) {
+	if key := t.target.Credential("access-key"); key != "" {
+		req.Header.Set("X-Auth-Key", key)
+	}
+}
+
+func (t *objectStoreTarget) client() *http.Client {
+	if !t.target.Insecure() {
+		return http.DefaultClient
+	}
+	return &http.Client{Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}}
+}
+
+// backupTargetView is the redacted, API-safe view of a state.BackupTarget:
+// credential values themselves are never sent to a client.
+type backupTargetView struct {
+	Name           string
+	Kind           state.BackupTargetKind
+	URL            string
+	Insecure       bool
+	HasCredentials bool
+}
+
+func redactBackupTarget(t *state.BackupTarget) backupTargetView {
+	return backupTargetView{
+		Name:           t.Name(),
+		Kind:           t.Kind(),
+		URL:            t.URL(),
+		Insecure:       t.Insecure(),
+		HasCredentials: t.HasCredentials(),
+	}
+}
+
+// backupTargetsHandler serves CRUD requests against "/backup/targets".
+type backupTargetsHandler struct {
+	httpContext
+}
+
+type backupTargetBody struct {
+	Name        string
+	Kind        state.BackupTargetKind
+	URL         string
+	Credentials map[string]string
+	Insecure    bool
+}
+
+func (h *backupTargetsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	st, err := h.stateForRequestAuthenticatedUser(r)
+	if err != nil {
+		sendError(w, http.StatusUnauthorized, err)
+		return
+	}
+	switch r.Method {
+	case "GET":
+		h.list(w, st)
+	case "POST":
+		h.create(w, r, st)
+	case "DELETE":
+		h.remove(w, r, st)
+	default:
+		sendError(w, http.StatusMethodNotAllowed, fmt.Errorf("unsupported method: %q", r.Method))
+	}
+}
+
+func (h *backupTargetsHandler) list(w http.ResponseWriter, st *state.State) {
+	targets, err := st.AllBackupTargets()
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+	views := make([]backupTargetView, len(targets))
+	for i, t := range targets {
+		views[i] = redactBackupTarget(t)
+	}
+	sendJSON(w, http.StatusOK, views)
+}
+
+func (h *backupTargetsHandler) create(w http.ResponseWriter, r *http.Request, st *state.State) {
+	var body backupTargetBody
+	if err := decodeJSONBody(r, &body); err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+	target, err := st.AddBackupTarget(body.Name, body.Kind, body.URL, body.Credentials, body.Insecure)
+	if err != nil {
+		if errors.IsAlreadyExists(err) {
+			sendError(w, http.StatusConflict, err)
+			return
+		}
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+	sendJSON(w, http.StatusCreated, redactBackupTarget(target))
+}
+
+func (h *backupTargetsHandler) remove(w http.ResponseWriter, r *http.Request, st *state.State) {
+	name := pathTail(r.URL.Path)
+	if err := st.RemoveBackupTarget(name); err != nil {
+		if errors.IsNotFound(err) {
+			sendError(w, http.StatusNotFound, err)
+			return
+		}
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
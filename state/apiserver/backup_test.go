@@ -4,10 +4,14 @@
 package apiserver_test
 
 import (
+	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/juju/juju/state"
 	"github.com/juju/juju/state/apiserver"
@@ -95,3 +99,149 @@ func (s *backupSuite) TestBackupCalledAndFileServed(c *gc.C) {
 	body, _ := ioutil.ReadAll(resp.Body)
 	c.Assert(body, jc.DeepEquals, []byte("foobarbam"))
 }
+
+func (s *backupSuite) backupPoliciesURL(c *gc.C) string {
+	uri := s.baseURL(c)
+	uri.Path += "/backup/policies"
+	return uri.String()
+}
+
+func (s *backupSuite) TestBackupPoliciesRoundTrip(c *gc.C) {
+	create := `{"Name": "nightly", "Cron": "0 2 * * *", "RetentionCount": 7, "Enabled": true}`
+	resp, err := s.authRequest(c, "POST", s.backupPoliciesURL(c), "application/json", strings.NewReader(create))
+	c.Assert(err, gc.IsNil)
+	c.Assert(resp.StatusCode, gc.Equals, http.StatusCreated)
+
+	policy, err := s.State.BackupPolicy("nightly")
+	c.Assert(err, gc.IsNil)
+	c.Assert(policy.Cron(), gc.Equals, "0 2 * * *")
+	c.Assert(policy.RetentionCount(), gc.Equals, 7)
+	c.Assert(policy.Enabled(), jc.IsTrue)
+
+	resp, err = s.authRequest(c, "GET", s.backupPoliciesURL(c), "", nil)
+	c.Assert(err, gc.IsNil)
+	c.Assert(resp.StatusCode, gc.Equals, http.StatusOK)
+	var policies []struct{ Name string }
+	c.Assert(json.NewDecoder(resp.Body).Decode(&policies), gc.IsNil)
+	c.Assert(policies, gc.HasLen, 1)
+	c.Assert(policies[0].Name, gc.Equals, "nightly")
+}
+
+func (s *backupSuite) TestBackupRunsListsAdHocRuns(c *gc.C) {
+	testBackup := func(tempDir string) (string, string, error) {
+		backupFilePath := filepath.Join(tempDir, "testBackupFile")
+		return backupFilePath, "some-sha", ioutil.WriteFile(backupFilePath, []byte("data"), 0644)
+	}
+	s.PatchValue(&apiserver.DoBackup, testBackup)
+
+	resp, err := s.authRequest(c, "POST", s.backupURL(c), "", nil)
+	c.Assert(err, gc.IsNil)
+	resp.Body.Close()
+
+	runs, err := s.State.BackupRuns("")
+	c.Assert(err, gc.IsNil)
+	c.Assert(runs, gc.HasLen, 1)
+	c.Assert(runs[0].SHA(), gc.Equals, "some-sha")
+	c.Assert(runs[0].Status(), gc.Equals, state.BackupRunComplete)
+}
+
+// fakeUploadTarget is an in-memory stand-in for a real remote storage
+// backend, used to test target routing without talking to S3 or Swift.
+type fakeUploadTarget struct {
+	uploaded map[string][]byte
+	failErr  error
+}
+
+func (t *fakeUploadTarget) Upload(archivePath, key string) error {
+	if t.failErr != nil {
+		return t.failErr
+	}
+	data, err := ioutil.ReadFile(archivePath)
+	if err != nil {
+		return err
+	}
+	t.uploaded[key] = data
+	return nil
+}
+
+func (t *fakeUploadTarget) SignedURL(key string, expiry time.Duration) (string, error) {
+	return "https://fake-target.example/" + key, nil
+}
+
+func (s *backupSuite) backupTargetsURL(c *gc.C) string {
+	uri := s.baseURL(c)
+	uri.Path += "/backup/targets"
+	return uri.String()
+}
+
+func (s *backupSuite) TestBackupTargetsCreateRedactsCredentials(c *gc.C) {
+	create := `{"Name": "swift1", "Kind": "swift", "URL": "https://swift.example/container", ` +
+		`"Credentials": {"access-key": "secret-value"}}`
+	resp, err := s.authRequest(c, "POST", s.backupTargetsURL(c), "application/json", strings.NewReader(create))
+	c.Assert(err, gc.IsNil)
+	c.Assert(resp.StatusCode, gc.Equals, http.StatusCreated)
+
+	body, err := ioutil.ReadAll(resp.Body)
+	c.Assert(err, gc.IsNil)
+	c.Assert(strings.Contains(string(body), "secret-value"), jc.IsFalse)
+
+	var created struct {
+		HasCredentials bool
+	}
+	c.Assert(json.Unmarshal(body, &created), gc.IsNil)
+	c.Assert(created.HasCredentials, jc.IsTrue)
+
+	resp, err = s.authRequest(c, "GET", s.backupTargetsURL(c), "", nil)
+	c.Assert(err, gc.IsNil)
+	listBody, err := ioutil.ReadAll(resp.Body)
+	c.Assert(err, gc.IsNil)
+	c.Assert(strings.Contains(string(listBody), "secret-value"), jc.IsFalse)
+}
+
+func (s *backupSuite) TestBackupRoutesUploadToTarget(c *gc.C) {
+	_, err := s.State.AddBackupTarget("local1", state.BackupTargetLocal, "/var/backups/juju", nil, false)
+	c.Assert(err, gc.IsNil)
+	fake := &fakeUploadTarget{uploaded: map[string][]byte{}}
+	s.PatchValue(&apiserver.NewUploadTarget, func(*state.BackupTarget) (apiserver.UploadTarget, error) {
+		return fake, nil
+	})
+	testBackup := func(tempDir string) (string, string, error) {
+		backupFilePath := filepath.Join(tempDir, "testBackupFile")
+		return backupFilePath, "target-sha", ioutil.WriteFile(backupFilePath, []byte("payload"), 0644)
+	}
+	s.PatchValue(&apiserver.DoBackup, testBackup)
+
+	resp, err := s.authRequest(c, "POST", s.backupURL(c)+"?target=local1", "", nil)
+	c.Assert(err, gc.IsNil)
+	resp.Body.Close()
+	c.Assert(resp.StatusCode, gc.Equals, http.StatusOK)
+	c.Assert(fake.uploaded["target-sha"], jc.DeepEquals, []byte("payload"))
+
+	runs, err := s.State.BackupRuns("")
+	c.Assert(err, gc.IsNil)
+	c.Assert(runs, gc.HasLen, 1)
+	c.Assert(runs[0].TargetId(), gc.Equals, "local1")
+}
+
+func (s *backupSuite) TestBackupUploadFailureIsReported(c *gc.C) {
+	_, err := s.State.AddBackupTarget("broken", state.BackupTargetLocal, "/var/backups/juju", nil, false)
+	c.Assert(err, gc.IsNil)
+	fake := &fakeUploadTarget{uploaded: map[string][]byte{}, failErr: fmt.Errorf("connection refused")}
+	s.PatchValue(&apiserver.NewUploadTarget, func(*state.BackupTarget) (apiserver.UploadTarget, error) {
+		return fake, nil
+	})
+	testBackup := func(tempDir string) (string, string, error) {
+		backupFilePath := filepath.Join(tempDir, "testBackupFile")
+		return backupFilePath, "some-sha", ioutil.WriteFile(backupFilePath, []byte("payload"), 0644)
+	}
+	s.PatchValue(&apiserver.DoBackup, testBackup)
+
+	resp, err := s.authRequest(c, "POST", s.backupURL(c)+"?target=broken", "", nil)
+	c.Assert(err, gc.IsNil)
+	s.assertErrorResponse(c, resp, http.StatusBadGateway, "connection refused")
+
+	runs, err := s.State.BackupRuns("")
+	c.Assert(err, gc.IsNil)
+	c.Assert(runs, gc.HasLen, 1)
+	c.Assert(runs[0].Status(), gc.Equals, state.BackupRunFailed)
+}
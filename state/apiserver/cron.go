@@ -0,0 +1,19 @@
+// Copyright 2015 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package apiserver
+
+import (
+	"github.com/juju/juju/cron"
+)
+
+// cronSchedule is a parsed standard 5-field cron expression, used by
+// the backup and relation-GC schedulers. It is an alias for
+// cron.Schedule so the two schedulers don't need to spell out the
+// import at every use.
+type cronSchedule = cron.Schedule
+
+// parseCronSchedule parses a standard 5-field cron expression.
+func parseCronSchedule(expr string) (*cronSchedule, error) {
+	return cron.Parse(expr)
+}
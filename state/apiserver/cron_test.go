@@ -0,0 +1,28 @@
+// Copyright 2015 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package apiserver
+
+import (
+	"time"
+
+	gc "launchpad.net/gocheck"
+)
+
+type cronSuite struct{}
+
+var _ = gc.Suite(&cronSuite{})
+
+// TestParseCronScheduleWrapsCronPackage is a smoke test that
+// parseCronSchedule/cronSchedule correctly delegate to the shared
+// cron package; the parser and Next() behaviour itself is covered by
+// cron's own tests.
+func (s *cronSuite) TestParseCronScheduleWrapsCronPackage(c *gc.C) {
+	sched, err := parseCronSchedule("0 2 * * *")
+	c.Assert(err, gc.IsNil)
+	after := time.Date(2015, 1, 1, 3, 0, 0, 0, time.UTC)
+	c.Assert(sched.Next(after), gc.Equals, time.Date(2015, 1, 2, 2, 0, 0, 0, time.UTC))
+
+	_, err = parseCronSchedule("* * *")
+	c.Assert(err, gc.ErrorMatches, `invalid cron expression "\* \* \*": expected 5 fields, got 3`)
+}
@@ -0,0 +1,55 @@
+// Copyright 2015 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package apiserver
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/juju/juju/state"
+)
+
+// gcRelationsHandler serves "/gc/relations", allowing an administrator
+// to trigger relation garbage collection on demand rather than waiting
+// for the next scheduled run.
+type gcRelationsHandler struct {
+	httpContext
+}
+
+// gcRelationsBody is the JSON body accepted by a POST to
+// "/gc/relations". A zero MaxAge collects every eligible relation; a
+// zero Limit does not cap the number collected.
+type gcRelationsBody struct {
+	MaxAge time.Duration
+	Limit  int
+	DryRun bool
+}
+
+func (h *gcRelationsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	st, err := h.stateForRequestAuthenticatedUser(r)
+	if err != nil {
+		sendError(w, http.StatusUnauthorized, err)
+		return
+	}
+	if r.Method != "POST" {
+		sendError(w, http.StatusMethodNotAllowed, fmt.Errorf("unsupported method: %q", r.Method))
+		return
+	}
+	var body gcRelationsBody
+	if err := decodeJSONBody(r, &body); err != nil {
+		sendError(w, http.StatusBadRequest, err)
+		return
+	}
+	report, err := st.GarbageCollectRelations(state.GCOptions{
+		MaxAge: body.MaxAge,
+		Limit:  body.Limit,
+		DryRun: body.DryRun,
+	})
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, err)
+		return
+	}
+	sendJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,83 @@
+// Copyright 2015 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package apiserver
+
+import (
+	"time"
+
+	"github.com/juju/juju/state"
+)
+
+// gcScheduler periodically runs relation garbage collection according
+// to a cron schedule, independently of the "/gc/relations" endpoint an
+// administrator can use to trigger a run on demand.
+type gcScheduler struct {
+	st    *state.State
+	sched *cronSchedule
+	opts  state.GCOptions
+	tick  time.Duration
+	done  chan struct{}
+}
+
+// newGCScheduler returns a scheduler that checks, every tick, whether
+// cronExpr has a firing time due since it last looked, and if so runs
+// GarbageCollectRelations with opts.
+func newGCScheduler(st *state.State, cronExpr string, opts state.GCOptions, tick time.Duration) (*gcScheduler, error) {
+	sched, err := parseCronSchedule(cronExpr)
+	if err != nil {
+		return nil, err
+	}
+	return &gcScheduler{
+		st:    st,
+		sched: sched,
+		opts:  opts,
+		tick:  tick,
+		done:  make(chan struct{}),
+	}, nil
+}
+
+// run checks the schedule once per tick until Kill is called. It is
+// meant to be started with "go s.run()".
+func (s *gcScheduler) run() {
+	last := time.Now()
+	ticker := time.NewTicker(s.tick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case now := <-ticker.C:
+			s.checkDue(last, now)
+			last = now
+		}
+	}
+}
+
+// Kill stops the scheduler. It does not wait for a run in progress to
+// finish.
+func (s *gcScheduler) Kill() {
+	close(s.done)
+}
+
+// checkDue runs GarbageCollectRelations if the schedule has a firing
+// time in (since, now].
+func (s *gcScheduler) checkDue(since, now time.Time) {
+	due := s.sched.Next(since)
+	if due.IsZero() || due.After(now) {
+		return
+	}
+	report, err := s.st.GarbageCollectRelations(s.opts)
+	if err != nil {
+		logger.Errorf("relation garbage collection failed: %v", err)
+		return
+	}
+	if s.opts.DryRun {
+		logger.Infof("relation garbage collection dry run would reap %d relation(s)", len(report.Reaped))
+	} else {
+		logger.Infof("relation garbage collection reaped %d relation(s)", len(report.Reaped))
+	}
+	for key, err := range report.Errors {
+		logger.Errorf("cannot garbage collect relation %q: %v", key, err)
+	}
+}
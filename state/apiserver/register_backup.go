@@ -0,0 +1,34 @@
+// Copyright 2015 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package apiserver
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/juju/juju/state"
+)
+
+// AddBackupHandlers attaches the backup-related HTTP handlers to mux,
+// authenticating requests via ctx. It is called once during server
+// startup, alongside the other facade and handler registrations.
+func AddBackupHandlers(mux *http.ServeMux, ctx httpContext) {
+	mux.Handle("/backup", &backupHandler{ctx})
+	mux.Handle("/backup/", &backupHandler{ctx})
+	mux.Handle("/backup/policies", &backupPoliciesHandler{ctx})
+	mux.Handle("/backup/policies/", &backupPoliciesHandler{ctx})
+	mux.Handle("/backup/runs", &backupRunsHandler{ctx})
+	mux.Handle("/backup/runs/", &backupRunsHandler{ctx})
+	mux.Handle("/backup/targets", &backupTargetsHandler{ctx})
+	mux.Handle("/backup/targets/", &backupTargetsHandler{ctx})
+}
+
+// StartBackupScheduler starts the scheduler that fires enabled backup
+// policies according to their cron schedule, checking for due
+// policies every tick. The returned func stops it.
+func StartBackupScheduler(st *state.State, tick time.Duration) func() {
+	s := newBackupScheduler(st, tick)
+	go s.run()
+	return s.Kill
+}
@@ -0,0 +1,30 @@
+// Copyright 2015 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package apiserver
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/juju/juju/state"
+)
+
+// AddGCHandlers attaches the relation-GC HTTP handler to mux,
+// authenticating requests via ctx. It is called once during server
+// startup, alongside the other facade and handler registrations.
+func AddGCHandlers(mux *http.ServeMux, ctx httpContext) {
+	mux.Handle("/gc/relations", &gcRelationsHandler{ctx})
+}
+
+// StartGCScheduler starts the relation GC scheduler, which checks
+// cronExpr every tick and runs GarbageCollectRelations with opts when
+// due. The returned func stops it.
+func StartGCScheduler(st *state.State, cronExpr string, opts state.GCOptions, tick time.Duration) (func(), error) {
+	s, err := newGCScheduler(st, cronExpr, opts, tick)
+	if err != nil {
+		return nil, err
+	}
+	go s.run()
+	return s.Kill, nil
+}
@@ -0,0 +1,282 @@
+// Copyright 2015 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package state
+
+import (
+	"time"
+
+	"github.com/juju/errors"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"gopkg.in/mgo.v2/txn"
+)
+
+// BackupRunStatus describes the outcome of a single backup run.
+type BackupRunStatus string
+
+const (
+	BackupRunPending  BackupRunStatus = "pending"
+	BackupRunRunning  BackupRunStatus = "running"
+	BackupRunComplete BackupRunStatus = "complete"
+	BackupRunFailed   BackupRunStatus = "failed"
+)
+
+// backupPolicyDoc is the persistent representation of a BackupPolicy.
+type backupPolicyDoc struct {
+	Name           string `bson:"_id"`
+	Cron           string
+	RetentionCount int
+	RetentionAge   time.Duration
+	TargetId       string
+	Enabled        bool
+}
+
+// BackupPolicy describes how, and how often, backups of the environment
+// should be taken and for how long the resulting runs should be kept.
+type BackupPolicy struct {
+	st  *State
+	doc backupPolicyDoc
+}
+
+// Name returns the policy's unique name.
+func (p *BackupPolicy) Name() string { return p.doc.Name }
+
+// Cron returns the cron expression on which the policy fires.
+func (p *BackupPolicy) Cron() string { return p.doc.Cron }
+
+// RetentionCount returns the number of runs to keep, or 0 if runs are
+// pruned on age alone.
+func (p *BackupPolicy) RetentionCount() int { return p.doc.RetentionCount }
+
+// RetentionAge returns the maximum age of a run to keep, or 0 if runs
+// are pruned on count alone.
+func (p *BackupPolicy) RetentionAge() time.Duration { return p.doc.RetentionAge }
+
+// TargetId returns the id of the BackupTarget runs of this policy
+// should be uploaded to, or "" for the environment's default target.
+func (p *BackupPolicy) TargetId() string { return p.doc.TargetId }
+
+// Enabled reports whether the scheduler should act on this policy.
+func (p *BackupPolicy) Enabled() bool { return p.doc.Enabled }
+
+// SetEnabled enables or disables the policy.
+func (p *BackupPolicy) SetEnabled(enabled bool) error {
+	ops := []txn.Op{{
+		C:      p.st.backupPolicies.Name,
+		Id:     p.doc.Name,
+		Assert: txn.DocExists,
+		Update: bson.D{{"$set", bson.D{{"enabled", enabled}}}},
+	}}
+	if err := p.st.runTransaction(ops); err != nil {
+		return errors.Annotatef(err, "cannot set enabled=%v on backup policy %q", enabled, p.doc.Name)
+	}
+	p.doc.Enabled = enabled
+	return nil
+}
+
+// AddBackupPolicy creates a new BackupPolicy with the given name. The
+// name must be unique.
+func (st *State) AddBackupPolicy(name, cron string, retentionCount int, retentionAge time.Duration, targetId string, enabled bool) (*BackupPolicy, error) {
+	doc := backupPolicyDoc{
+		Name:           name,
+		Cron:           cron,
+		RetentionCount: retentionCount,
+		RetentionAge:   retentionAge,
+		TargetId:       targetId,
+		Enabled:        enabled,
+	}
+	ops := []txn.Op{{
+		C:      st.backupPolicies.Name,
+		Id:     name,
+		Assert: txn.DocMissing,
+		Insert: &doc,
+	}}
+	if err := st.runTransaction(ops); err != nil {
+		if err == txn.ErrAborted {
+			return nil, errors.AlreadyExistsf("backup policy %q", name)
+		}
+		return nil, errors.Annotatef(err, "cannot add backup policy %q", name)
+	}
+	return &BackupPolicy{st: st, doc: doc}, nil
+}
+
+// BackupPolicy returns the named policy.
+func (st *State) BackupPolicy(name string) (*BackupPolicy, error) {
+	doc := backupPolicyDoc{}
+	err := st.backupPolicies.FindId(name).One(&doc)
+	if err == mgo.ErrNotFound {
+		return nil, errors.NotFoundf("backup policy %q", name)
+	} else if err != nil {
+		return nil, errors.Annotatef(err, "cannot get backup policy %q", name)
+	}
+	return &BackupPolicy{st: st, doc: doc}, nil
+}
+
+// AllBackupPolicies returns every backup policy in the environment.
+func (st *State) AllBackupPolicies() ([]*BackupPolicy, error) {
+	var docs []backupPolicyDoc
+	if err := st.backupPolicies.Find(nil).All(&docs); err != nil {
+		return nil, errors.Annotate(err, "cannot get backup policies")
+	}
+	policies := make([]*BackupPolicy, len(docs))
+	for i, doc := range docs {
+		policies[i] = &BackupPolicy{st: st, doc: doc}
+	}
+	return policies, nil
+}
+
+// RemoveBackupPolicy removes the named policy. It does not affect runs
+// already recorded for it.
+func (st *State) RemoveBackupPolicy(name string) error {
+	ops := []txn.Op{{
+		C:      st.backupPolicies.Name,
+		Id:     name,
+		Assert: txn.DocExists,
+		Remove: true,
+	}}
+	if err := st.runTransaction(ops); err != nil {
+		if err == txn.ErrAborted {
+			return errors.NotFoundf("backup policy %q", name)
+		}
+		return errors.Annotatef(err, "cannot remove backup policy %q", name)
+	}
+	return nil
+}
+
+// backupRunDoc is the persistent representation of a BackupRun.
+type backupRunDoc struct {
+	Id         string `bson:"_id"`
+	PolicyName string // empty for an ad-hoc, on-demand run
+	StartTime  time.Time
+	EndTime    time.Time
+	Status     BackupRunStatus
+	SHA        string
+	TargetId   string
+	Error      string
+}
+
+// BackupRun records one attempt, scheduled or ad-hoc, to take a backup.
+type BackupRun struct {
+	st  *State
+	doc backupRunDoc
+}
+
+func (r *BackupRun) Id() string              { return r.doc.Id }
+func (r *BackupRun) PolicyName() string      { return r.doc.PolicyName }
+func (r *BackupRun) StartTime() time.Time    { return r.doc.StartTime }
+func (r *BackupRun) Status() BackupRunStatus { return r.doc.Status }
+func (r *BackupRun) SHA() string             { return r.doc.SHA }
+func (r *BackupRun) TargetId() string        { return r.doc.TargetId }
+
+// SetStatus records the outcome of the run. endTime and sha are only
+// meaningful once status is BackupRunComplete; runErr is only
+// meaningful once status is BackupRunFailed.
+func (r *BackupRun) SetStatus(status BackupRunStatus, endTime time.Time, sha string, runErr error) error {
+	errMsg := ""
+	if runErr != nil {
+		errMsg = runErr.Error()
+	}
+	ops := []txn.Op{{
+		C:      r.st.backupRuns.Name,
+		Id:     r.doc.Id,
+		Assert: txn.DocExists,
+		Update: bson.D{{"$set", bson.D{
+			{"status", status},
+			{"endtime", endTime},
+			{"sha", sha},
+			{"error", errMsg},
+		}}},
+	}}
+	if err := r.st.runTransaction(ops); err != nil {
+		return errors.Annotatef(err, "cannot set status of backup run %q", r.doc.Id)
+	}
+	r.doc.Status, r.doc.EndTime, r.doc.SHA, r.doc.Error = status, endTime, sha, errMsg
+	return nil
+}
+
+// AddBackupRun records the start of a new backup run. policyName is
+// empty for an ad-hoc run triggered directly via the REST API.
+func (st *State) AddBackupRun(id, policyName, targetId string, startTime time.Time) (*BackupRun, error) {
+	doc := backupRunDoc{
+		Id:         id,
+		PolicyName: policyName,
+		TargetId:   targetId,
+		StartTime:  startTime,
+		Status:     BackupRunPending,
+	}
+	ops := []txn.Op{{
+		C:      st.backupRuns.Name,
+		Id:     id,
+		Assert: txn.DocMissing,
+		Insert: &doc,
+	}}
+	if err := st.runTransaction(ops); err != nil {
+		return nil, errors.Annotatef(err, "cannot record backup run %q", id)
+	}
+	return &BackupRun{st: st, doc: doc}, nil
+}
+
+// BackupRun returns the run with the given id.
+func (st *State) BackupRun(id string) (*BackupRun, error) {
+	doc := backupRunDoc{}
+	err := st.backupRuns.FindId(id).One(&doc)
+	if err == mgo.ErrNotFound {
+		return nil, errors.NotFoundf("backup run %q", id)
+	} else if err != nil {
+		return nil, errors.Annotatef(err, "cannot get backup run %q", id)
+	}
+	return &BackupRun{st: st, doc: doc}, nil
+}
+
+// BackupRuns returns every run recorded for policyName, most recent
+// first. An empty policyName selects ad-hoc runs.
+func (st *State) BackupRuns(policyName string) ([]*BackupRun, error) {
+	var docs []backupRunDoc
+	q := st.backupRuns.Find(bson.D{{"policyname", policyName}}).Sort("-starttime")
+	if err := q.All(&docs); err != nil {
+		return nil, errors.Annotatef(err, "cannot get backup runs for policy %q", policyName)
+	}
+	runs := make([]*BackupRun, len(docs))
+	for i, doc := range docs {
+		runs[i] = &BackupRun{st: st, doc: doc}
+	}
+	return runs, nil
+}
+
+// PruneBackupRuns removes all but the most recent keep runs of
+// policyName, and any run older than maxAge regardless of keep. Either
+// limit may be zero to disable it. It returns the ids removed.
+func (st *State) PruneBackupRuns(policyName string, keep int, maxAge time.Duration) ([]string, error) {
+	runs, err := st.BackupRuns(policyName)
+	if err != nil {
+		return nil, err
+	}
+	var stale []string
+	cutoff := time.Time{}
+	if maxAge > 0 {
+		cutoff = time.Now().Add(-maxAge)
+	}
+	for i, run := range runs {
+		tooMany := keep > 0 && i >= keep
+		tooOld := !cutoff.IsZero() && run.doc.StartTime.Before(cutoff)
+		if tooMany || tooOld {
+			stale = append(stale, run.doc.Id)
+		}
+	}
+	if len(stale) == 0 {
+		return nil, nil
+	}
+	ops := make([]txn.Op, len(stale))
+	for i, id := range stale {
+		ops[i] = txn.Op{
+			C:      st.backupRuns.Name,
+			Id:     id,
+			Remove: true,
+		}
+	}
+	if err := st.runTransaction(ops); err != nil {
+		return nil, errors.Annotatef(err, "cannot prune backup runs for policy %q", policyName)
+	}
+	return stale, nil
+}
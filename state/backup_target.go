@@ -0,0 +1,174 @@
+// Copyright 2015 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package state
+
+import (
+	"github.com/juju/errors"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"gopkg.in/mgo.v2/txn"
+)
+
+// BackupTargetKind identifies the kind of storage a BackupTarget talks
+// to, and therefore which client the apiserver should construct for it.
+type BackupTargetKind string
+
+const (
+	BackupTargetLocal BackupTargetKind = "local"
+	BackupTargetS3    BackupTargetKind = "s3"
+	BackupTargetSwift BackupTargetKind = "swift"
+)
+
+// backupTargetDoc is the persistent representation of a BackupTarget.
+type backupTargetDoc struct {
+	Name        string `bson:"_id"`
+	Kind        BackupTargetKind
+	URL         string
+	Credentials map[string]string
+	Insecure    bool
+}
+
+// BackupTarget is a named, remote location backups can be uploaded to.
+type BackupTarget struct {
+	st  *State
+	doc backupTargetDoc
+}
+
+// Name returns the target's unique name.
+func (t *BackupTarget) Name() string { return t.doc.Name }
+
+// Kind returns the kind of storage the target talks to.
+func (t *BackupTarget) Kind() BackupTargetKind { return t.doc.Kind }
+
+// URL returns the target's base URL (or, for a local target, base
+// directory).
+func (t *BackupTarget) URL() string { return t.doc.URL }
+
+// Insecure reports whether the target should be talked to without
+// verifying TLS certificates.
+func (t *BackupTarget) Insecure() bool { return t.doc.Insecure }
+
+// Credential returns the named credential value, or "" if it is unset.
+// Credentials are never exposed over the API; callers that need to
+// report on a target's configuration should use HasCredentials instead.
+func (t *BackupTarget) Credential(key string) string { return t.doc.Credentials[key] }
+
+// HasCredentials reports whether any credentials are configured for
+// the target, without revealing their values.
+func (t *BackupTarget) HasCredentials() bool { return len(t.doc.Credentials) > 0 }
+
+// AddBackupTarget creates a new BackupTarget with the given name. The
+// name must be unique.
+func (st *State) AddBackupTarget(name string, kind BackupTargetKind, url string, credentials map[string]string, insecure bool) (*BackupTarget, error) {
+	doc := backupTargetDoc{
+		Name:        name,
+		Kind:        kind,
+		URL:         url,
+		Credentials: credentials,
+		Insecure:    insecure,
+	}
+	ops := []txn.Op{{
+		C:      st.backupTargets.Name,
+		Id:     name,
+		Assert: txn.DocMissing,
+		Insert: &doc,
+	}}
+	if err := st.runTransaction(ops); err != nil {
+		if err == txn.ErrAborted {
+			return nil, errors.AlreadyExistsf("backup target %q", name)
+		}
+		return nil, errors.Annotatef(err, "cannot add backup target %q", name)
+	}
+	return &BackupTarget{st: st, doc: doc}, nil
+}
+
+// BackupTarget returns the named target.
+func (st *State) BackupTarget(name string) (*BackupTarget, error) {
+	doc := backupTargetDoc{}
+	err := st.backupTargets.FindId(name).One(&doc)
+	if err == mgo.ErrNotFound {
+		return nil, errors.NotFoundf("backup target %q", name)
+	} else if err != nil {
+		return nil, errors.Annotatef(err, "cannot get backup target %q", name)
+	}
+	return &BackupTarget{st: st, doc: doc}, nil
+}
+
+// AllBackupTargets returns every backup target in the environment.
+func (st *State) AllBackupTargets() ([]*BackupTarget, error) {
+	var docs []backupTargetDoc
+	if err := st.backupTargets.Find(nil).All(&docs); err != nil {
+		return nil, errors.Annotate(err, "cannot get backup targets")
+	}
+	targets := make([]*BackupTarget, len(docs))
+	for i, doc := range docs {
+		targets[i] = &BackupTarget{st: st, doc: doc}
+	}
+	return targets, nil
+}
+
+// RemoveBackupTarget removes the named target.
+func (st *State) RemoveBackupTarget(name string) error {
+	ops := []txn.Op{{
+		C:      st.backupTargets.Name,
+		Id:     name,
+		Assert: txn.DocExists,
+		Remove: true,
+	}}
+	if err := st.runTransaction(ops); err != nil {
+		if err == txn.ErrAborted {
+			return errors.NotFoundf("backup target %q", name)
+		}
+		return errors.Annotatef(err, "cannot remove backup target %q", name)
+	}
+	return nil
+}
+
+// backupTargetSettingsId is the fixed id of the singleton document that
+// records the environment's default backup target.
+const backupTargetSettingsId = "backupTargetSettings"
+
+type backupTargetSettingsDoc struct {
+	Id                string `bson:"_id"`
+	DefaultTargetName string
+}
+
+// SetDefaultBackupTarget records name as the target ad-hoc and
+// scheduled backups upload to when no "?target=" is given. An empty
+// name clears the default.
+func (st *State) SetDefaultBackupTarget(name string) error {
+	ops := []txn.Op{{
+		C:      st.backupTargetSettings.Name,
+		Id:     backupTargetSettingsId,
+		Assert: txn.DocMissing,
+		Insert: &backupTargetSettingsDoc{Id: backupTargetSettingsId, DefaultTargetName: name},
+	}}
+	err := st.runTransaction(ops)
+	if err == txn.ErrAborted {
+		ops = []txn.Op{{
+			C:      st.backupTargetSettings.Name,
+			Id:     backupTargetSettingsId,
+			Assert: txn.DocExists,
+			Update: bson.D{{"$set", bson.D{{"defaulttargetname", name}}}},
+		}}
+		err = st.runTransaction(ops)
+	}
+	if err != nil {
+		return errors.Annotate(err, "cannot set default backup target")
+	}
+	return nil
+}
+
+// DefaultBackupTargetName returns the name set by SetDefaultBackupTarget,
+// or errors.NotFound if none has been set.
+func (st *State) DefaultBackupTargetName() (string, error) {
+	doc := backupTargetSettingsDoc{}
+	err := st.backupTargetSettings.FindId(backupTargetSettingsId).One(&doc)
+	if err == mgo.ErrNotFound || (err == nil && doc.DefaultTargetName == "") {
+		return "", errors.NotFoundf("default backup target")
+	} else if err != nil {
+		return "", errors.Annotate(err, "cannot get default backup target")
+	}
+	return doc.DefaultTargetName, nil
+}
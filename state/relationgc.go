@@ -0,0 +1,164 @@
+// Copyright 2015 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package state
+
+import (
+	"strconv"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+	"gopkg.in/mgo.v2/txn"
+)
+
+// GCOptions controls a single run of (*State).GarbageCollectRelations.
+type GCOptions struct {
+	// MaxAge, if non-zero, restricts reaping to relations that have
+	// been Dead for at least this long. A relation whose DiedAt is
+	// the zero Time is always considered old enough.
+	MaxAge time.Duration
+
+	// Limit, if non-zero, caps the number of relations reaped in a
+	// single run.
+	Limit int
+
+	// DryRun, if true, computes what would be reaped without
+	// removing anything.
+	DryRun bool
+}
+
+// GCReport summarises the outcome of a GarbageCollectRelations run.
+type GCReport struct {
+	// Reaped holds the keys of the relations that were removed (or,
+	// for a dry run, would have been removed).
+	Reaped []string
+
+	// FreedSettings holds the ids of the settings documents that were
+	// removed (or would have been removed) along with their owning
+	// relations.
+	FreedSettings []string
+
+	// Errors maps the key of a candidate relation to the error
+	// encountered while trying to reap it. A relation that could not
+	// be inspected or removed is skipped rather than aborting the
+	// whole run.
+	Errors map[string]error
+}
+
+// gcRelationDoc holds the fields of a relationDoc that relation
+// garbage collection needs to inspect; it is decoded straight from
+// the relations collection rather than the full relationDoc.
+type gcRelationDoc struct {
+	Key    string `bson:"_id"`
+	Id     int
+	Life   Life
+	DiedAt time.Time
+}
+
+// relationScopePrefix returns the prefix shared by every relationRefs
+// and settings document belonging to relation id, as established by
+// RelationUnit.scope and RelationUnit.key.
+func relationScopePrefix(id int) string {
+	return "r#" + strconv.Itoa(id) + "#"
+}
+
+// GarbageCollectRelations removes Dead relations that no unit still
+// references, along with the settings documents they own. A relation
+// is only removed once its relationRefs collection has no documents
+// whose key falls under the relation's scope, since such a document
+// indicates a unit may still be watching or participating in it; that
+// check is made by reapRelation immediately before it builds and
+// runs the removal transaction, to keep the window in which a
+// concurrent join could race past it as small as possible.
+//
+// Each relation is reaped in its own transaction, so a failure or
+// race on one candidate does not prevent the others from being
+// collected; any such failure is recorded in the returned GCReport
+// rather than aborting the run.
+func (st *State) GarbageCollectRelations(opts GCOptions) (GCReport, error) {
+	report := GCReport{Errors: make(map[string]error)}
+
+	var docs []gcRelationDoc
+	query := st.relations.Find(bson.D{{"life", Dead}})
+	if err := query.Sort("diedat").All(&docs); err != nil {
+		return report, err
+	}
+
+	for _, doc := range docs {
+		if opts.Limit > 0 && len(report.Reaped) >= opts.Limit {
+			break
+		}
+		if opts.MaxAge > 0 && !doc.DiedAt.IsZero() && time.Since(doc.DiedAt) < opts.MaxAge {
+			continue
+		}
+		freed, err := st.reapRelation(doc, opts.DryRun)
+		if err != nil {
+			report.Errors[doc.Key] = err
+			continue
+		}
+		if freed == nil {
+			// Still referenced; nothing to do.
+			continue
+		}
+		report.Reaped = append(report.Reaped, doc.Key)
+		report.FreedSettings = append(report.FreedSettings, freed...)
+	}
+	return report, nil
+}
+
+// reapRelation removes doc and every settings document in its scope,
+// provided no relationRefs document still references it. It returns
+// nil, nil if the relation is still referenced and so was left alone.
+//
+// The relationRefs check is done as late as possible, immediately
+// before the removal transaction is built and run, to minimise the
+// window in which a concurrent RelationUnit.EnsureJoin could insert a
+// ref after it has been seen to be absent; mgo/txn can only assert
+// conditions on documents it identifies by id, so it cannot itself
+// assert "no document matching this prefix exists" as part of the
+// same transaction. Closing that window completely would require a
+// refcount field on the relation document itself, maintained
+// transactionally by EnsureJoin/EnsureDepart.
+func (st *State) reapRelation(doc gcRelationDoc, dryRun bool) ([]string, error) {
+	prefix := relationScopePrefix(doc.Id)
+	refsQuery := bson.D{{"_id", bson.RegEx{Pattern: "^" + prefix}}}
+	if n, err := st.relationRefs.Find(refsQuery).Count(); err != nil {
+		return nil, err
+	} else if n > 0 {
+		return nil, nil
+	}
+
+	var settingsDocs []struct {
+		Key string `bson:"_id"`
+	}
+	settingsQuery := bson.D{{"_id", bson.RegEx{Pattern: "^" + prefix}}}
+	if err := st.settings.Find(settingsQuery).All(&settingsDocs); err != nil {
+		return nil, err
+	}
+	freed := make([]string, len(settingsDocs))
+	for i, s := range settingsDocs {
+		freed[i] = s.Key
+	}
+
+	if dryRun {
+		return freed, nil
+	}
+
+	ops := []txn.Op{{
+		C:      st.relations.Name,
+		Id:     doc.Key,
+		Assert: bson.D{{"life", Dead}},
+		Remove: true,
+	}}
+	for _, key := range freed {
+		ops = append(ops, txn.Op{
+			C:      st.settings.Name,
+			Id:     key,
+			Remove: true,
+		})
+	}
+	if err := st.runTransaction(ops); err != nil {
+		return nil, err
+	}
+	return freed, nil
+}
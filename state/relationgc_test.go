@@ -0,0 +1,145 @@
+// Copyright 2015 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package state
+
+import (
+	"strings"
+	"time"
+
+	gitjujutesting "github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	gc "launchpad.net/gocheck"
+)
+
+type relationGCSuite struct{}
+
+var _ = gc.Suite(&relationGCSuite{})
+
+func (s *relationGCSuite) TestRelationScopePrefix(c *gc.C) {
+	c.Assert(relationScopePrefix(1), gc.Equals, "r#1#")
+	c.Assert(relationScopePrefix(10), gc.Equals, "r#10#")
+}
+
+func (s *relationGCSuite) TestRelationScopePrefixDoesNotCollideAcrossIds(c *gc.C) {
+	// A settings key belonging to relation 1 ("r#1#...") must not match
+	// the prefix for relation 10 ("r#10#"), or vice versa.
+	c.Assert(strings.HasPrefix("r#1#requirer#wordpress/0", relationScopePrefix(10)), gc.Equals, false)
+	c.Assert(strings.HasPrefix("r#10#requirer#wordpress/0", relationScopePrefix(1)), gc.Equals, false)
+}
+
+// relationGCStateSuite exercises GarbageCollectRelations and reapRelation
+// end to end, against a real (if ephemeral) mongo, by populating the
+// relations/relationRefs/settings collections directly rather than going
+// through the higher-level relation API.
+type relationGCStateSuite struct {
+	gitjujutesting.MgoSuite
+	session *mgo.Session
+	st      *State
+}
+
+var _ = gc.Suite(&relationGCStateSuite{})
+
+func (s *relationGCStateSuite) SetUpTest(c *gc.C) {
+	s.MgoSuite.SetUpTest(c)
+	session, err := mgo.Dial(gitjujutesting.MgoServer.Addr())
+	c.Assert(err, gc.IsNil)
+	s.session = session
+	db := session.DB("relationgc-test")
+	s.st = &State{
+		relations:    db.C("relations"),
+		relationRefs: db.C("relationRefs"),
+		settings:     db.C("settings"),
+	}
+}
+
+func (s *relationGCStateSuite) TearDownTest(c *gc.C) {
+	s.session.Close()
+	s.MgoSuite.TearDownTest(c)
+}
+
+func (s *relationGCStateSuite) addRelation(c *gc.C, key string, id int, diedAt time.Time) {
+	err := s.st.relations.Insert(gcRelationDoc{
+		Key:    key,
+		Id:     id,
+		Life:   Dead,
+		DiedAt: diedAt,
+	})
+	c.Assert(err, gc.IsNil)
+}
+
+func (s *relationGCStateSuite) addSettings(c *gc.C, key string) {
+	err := s.st.settings.Insert(bson.D{{"_id", key}})
+	c.Assert(err, gc.IsNil)
+}
+
+func (s *relationGCStateSuite) addRef(c *gc.C, key string) {
+	err := s.st.relationRefs.Insert(bson.D{{"_id", key}})
+	c.Assert(err, gc.IsNil)
+}
+
+func (s *relationGCStateSuite) TestGarbageCollectRelationsReapsUnreferenced(c *gc.C) {
+	s.addRelation(c, "wordpress:db mysql:db", 1, time.Time{})
+	s.addSettings(c, "r#1#requirer#wordpress/0")
+	s.addSettings(c, "r#1#provider#mysql/0")
+
+	report, err := s.st.GarbageCollectRelations(GCOptions{})
+	c.Assert(err, gc.IsNil)
+	c.Assert(report.Errors, gc.HasLen, 0)
+	c.Assert(report.Reaped, gc.DeepEquals, []string{"wordpress:db mysql:db"})
+	c.Assert(report.FreedSettings, jc.SameContents, []string{
+		"r#1#requirer#wordpress/0", "r#1#provider#mysql/0",
+	})
+
+	n, err := s.st.relations.FindId("wordpress:db mysql:db").Count()
+	c.Assert(err, gc.IsNil)
+	c.Assert(n, gc.Equals, 0)
+	n, err = s.st.settings.Find(nil).Count()
+	c.Assert(err, gc.IsNil)
+	c.Assert(n, gc.Equals, 0)
+}
+
+func (s *relationGCStateSuite) TestGarbageCollectRelationsSkipsStillReferenced(c *gc.C) {
+	s.addRelation(c, "wordpress:db mysql:db", 2, time.Time{})
+	s.addSettings(c, "r#2#requirer#wordpress/0")
+	s.addRef(c, "r#2#requirer#wordpress/0")
+
+	report, err := s.st.GarbageCollectRelations(GCOptions{})
+	c.Assert(err, gc.IsNil)
+	c.Assert(report.Reaped, gc.HasLen, 0)
+	c.Assert(report.FreedSettings, gc.HasLen, 0)
+
+	n, err := s.st.relations.FindId("wordpress:db mysql:db").Count()
+	c.Assert(err, gc.IsNil)
+	c.Assert(n, gc.Equals, 1)
+	n, err = s.st.settings.Find(nil).Count()
+	c.Assert(err, gc.IsNil)
+	c.Assert(n, gc.Equals, 1)
+}
+
+func (s *relationGCStateSuite) TestGarbageCollectRelationsRespectsMaxAge(c *gc.C) {
+	s.addRelation(c, "wordpress:db mysql:db", 3, time.Now())
+
+	report, err := s.st.GarbageCollectRelations(GCOptions{MaxAge: time.Hour})
+	c.Assert(err, gc.IsNil)
+	c.Assert(report.Reaped, gc.HasLen, 0)
+
+	n, err := s.st.relations.FindId("wordpress:db mysql:db").Count()
+	c.Assert(err, gc.IsNil)
+	c.Assert(n, gc.Equals, 1)
+}
+
+func (s *relationGCStateSuite) TestGarbageCollectRelationsDryRunChangesNothing(c *gc.C) {
+	s.addRelation(c, "wordpress:db mysql:db", 4, time.Time{})
+	s.addSettings(c, "r#4#requirer#wordpress/0")
+
+	report, err := s.st.GarbageCollectRelations(GCOptions{DryRun: true})
+	c.Assert(err, gc.IsNil)
+	c.Assert(report.Reaped, gc.DeepEquals, []string{"wordpress:db mysql:db"})
+
+	n, err := s.st.relations.FindId("wordpress:db mysql:db").Count()
+	c.Assert(err, gc.IsNil)
+	c.Assert(n, gc.Equals, 1)
+}